@@ -0,0 +1,204 @@
+// Package auth provides a password login flow built on the helpers of the
+// tools package: captcha check, bcrypt comparison, lockout and JWT issuing.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/griffin702/service/captcha"
+	"github.com/griffin702/service/tools"
+)
+
+var (
+	// ErrCaptchaInvalid is returned when the captcha answer is missing or wrong.
+	ErrCaptchaInvalid = errors.New("captcha is invalid")
+	// ErrInvalidCredentials is returned for an unknown user or a wrong password,
+	// both cases are reported the same way on purpose.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrLocked is returned while an account is locked after too many failures.
+	ErrLocked = errors.New("account is temporarily locked")
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// getDummyHash returns the hash compared when the user does not exist,
+// so the response time does not tell whether a username is taken.
+// It's generated on first use rather than when the package is imported.
+func getDummyHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash = tools.Tools.BcryptHashGenerate("dummy password")
+	})
+	return dummyHash
+}
+
+// Config is a struct for specifying configuration options of the login flow.
+type Config struct {
+	// Users looks up the accounts, required.
+	Users UserStore
+	// Captchas stores the captcha answers, when nil the captcha is not checked.
+	Captchas CaptchaStore
+	// Lockout counts the failures per username.
+	// Default: NewMemoryLockoutStore()
+	Lockout LockoutStore
+	// MaxFailures is the number of consecutive failures that locks an account.
+	// Default: 5
+	MaxFailures int
+	// LockoutDuration is how long an account stays locked.
+	// Default: 15 minutes
+	LockoutDuration time.Duration
+	// Secret signs the issued HS256 tokens, required.
+	Secret string
+	// Issuer is set as the "iss" claim when not empty.
+	Issuer string
+	// TokenExpiration is the lifetime of the issued tokens.
+	// Default: 2 hours
+	TokenExpiration time.Duration
+	// CaptchaExpiration is the lifetime of a captcha.
+	// Default: 5 minutes
+	CaptchaExpiration time.Duration
+	// Captcha image options, passed to tools.Tool.CaptchaGenerate.
+	// Default: 120x40, 4 characters, mode 0, fonts from "configs/captcha"
+	CaptchaWidth, CaptchaHeight, CaptchaLen, CaptchaMode int
+	CaptchaFontPath, CaptchaFontName                     string
+}
+
+// Service runs the login flow.
+type Service struct {
+	Config Config
+}
+
+// New constructs a new login Service with supplied options,
+// it returns an error when Users or Secret is not set.
+func New(c Config) (*Service, error) {
+	if c.Users == nil {
+		return nil, errors.New("auth: Config.Users is required")
+	}
+	if c.Secret == "" {
+		return nil, errors.New("auth: Config.Secret is required")
+	}
+	if c.Lockout == nil {
+		c.Lockout = NewMemoryLockoutStore()
+	}
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = 5
+	}
+	if c.LockoutDuration <= 0 {
+		c.LockoutDuration = 15 * time.Minute
+	}
+	if c.TokenExpiration <= 0 {
+		c.TokenExpiration = 2 * time.Hour
+	}
+	if c.CaptchaExpiration <= 0 {
+		c.CaptchaExpiration = 5 * time.Minute
+	}
+	if c.CaptchaWidth <= 0 {
+		c.CaptchaWidth = 120
+	}
+	if c.CaptchaHeight <= 0 {
+		c.CaptchaHeight = 40
+	}
+	if c.CaptchaFontPath == "" || c.CaptchaFontName == "" {
+		c.CaptchaFontPath, c.CaptchaFontName = "configs", "captcha"
+	}
+	return &Service{Config: c}, nil
+}
+
+// LoginRequest is the payload of a password login.
+type LoginRequest struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	CaptchaID string `json:"captcha_id"`
+	Captcha   string `json:"captcha"`
+}
+
+// LoginResult is the outcome of a successful login.
+type LoginResult struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+	User      *User  `json:"-"`
+}
+
+// NewCaptcha generates a captcha, stores its answer and returns its id and image.
+func (s *Service) NewCaptcha() (id string, image captcha.Image, err error) {
+	c := s.Config
+	if c.Captchas == nil {
+		return "", nil, errors.New("auth: no captcha store configured")
+	}
+	code, image, err := tools.Tools.CaptchaGenerate(c.CaptchaWidth, c.CaptchaHeight, c.CaptchaLen, c.CaptchaMode,
+		false, c.CaptchaFontPath, c.CaptchaFontName)
+	if err != nil {
+		return "", nil, err
+	}
+	id = tools.Tools.MustUUID()
+	if err = c.Captchas.Set(id, code, c.CaptchaExpiration); err != nil {
+		return "", nil, err
+	}
+	return id, image, nil
+}
+
+// Login checks the captcha and the password of the user and issues a token.
+func (s *Service) Login(ctx context.Context, req LoginRequest) (*LoginResult, error) {
+	c := s.Config
+	if c.Captchas != nil && !c.Captchas.Verify(req.CaptchaID, req.Captcha) {
+		return nil, ErrCaptchaInvalid
+	}
+
+	key := strings.ToLower(strings.TrimSpace(req.Username))
+	if key == "" || req.Password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	if !c.Lockout.LockedUntil(key).IsZero() {
+		return nil, ErrLocked
+	}
+
+	user, err := c.Users.FindByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	hash := getDummyHash()
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if !tools.Tools.BcryptHashCompare(hash, req.Password) || user == nil {
+		c.Lockout.Fail(key, c.MaxFailures, c.LockoutDuration)
+		return nil, ErrInvalidCredentials
+	}
+	c.Lockout.Reset(key)
+
+	return s.Issue(user)
+}
+
+// Issue signs a token for the user with its claims.
+func (s *Service) Issue(user *User) (*LoginResult, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.Config.TokenExpiration).Unix()
+	claims := jwt.MapClaims{}
+	for k, v := range user.Claims {
+		claims[k] = v
+	}
+	claims["sub"] = user.ID
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt
+	if s.Config.Issuer != "" {
+		claims["iss"] = s.Config.Issuer
+	}
+	// Signed like tools.Tool.JwtGenerate, which drops the error
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.Config.Secret))
+	if err != nil {
+		return nil, fmt.Errorf("auth: signing token: %w", err)
+	}
+	return &LoginResult{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      user,
+	}, nil
+}
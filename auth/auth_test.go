@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/griffin702/service/tools"
+	"github.com/kataras/iris/v12"
+)
+
+const testSecret = "test secret"
+
+// memoryUsers is an in-memory UserStore.
+type memoryUsers map[string]*User
+
+func (m memoryUsers) FindByUsername(_ context.Context, username string) (*User, error) {
+	return m[username], nil
+}
+
+// failingUsers is a UserStore whose backend is down.
+type failingUsers struct{}
+
+func (failingUsers) FindByUsername(context.Context, string) (*User, error) {
+	return nil, errors.New("database is down")
+}
+
+var testUsers = memoryUsers{
+	"alice": {
+		ID:           "1",
+		Username:     "alice",
+		PasswordHash: tools.Tools.BcryptHashGenerate("correct horse"),
+		Claims:       map[string]interface{}{"role": "admin"},
+	},
+}
+
+func newService(t *testing.T, c Config) *Service {
+	t.Helper()
+	if c.Users == nil {
+		c.Users = testUsers
+	}
+	if c.Secret == "" {
+		c.Secret = testSecret
+	}
+	s, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewRequiresUsersAndSecret(t *testing.T) {
+	if _, err := New(Config{Secret: testSecret}); err == nil {
+		t.Error("expected an error without Users")
+	}
+	if _, err := New(Config{Users: testUsers}); err == nil {
+		t.Error("expected an error without Secret")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newService(t, Config{Issuer: "test"})
+	result, err := s.Login(context.Background(), LoginRequest{Username: "alice", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(result.Token, func(*jwt.Token) (interface{}, error) { return []byte(testSecret), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["sub"] != "1" || claims["iss"] != "test" || claims["role"] != "admin" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+	if int64(claims["exp"].(float64)) != result.ExpiresAt {
+		t.Fatalf("expected exp %d but got %v", result.ExpiresAt, claims["exp"])
+	}
+}
+
+func TestLoginErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Config
+		req  LoginRequest
+		err  error
+	}{
+		{"wrong password", Config{}, LoginRequest{Username: "alice", Password: "wrong"}, ErrInvalidCredentials},
+		{"unknown user", Config{}, LoginRequest{Username: "bob", Password: "correct horse"}, ErrInvalidCredentials},
+		{"empty password", Config{}, LoginRequest{Username: "alice"}, ErrInvalidCredentials},
+		{"empty username", Config{}, LoginRequest{Password: "correct horse"}, ErrInvalidCredentials},
+		{"missing captcha", Config{Captchas: NewMemoryCaptchaStore()}, LoginRequest{Username: "alice", Password: "correct horse"}, ErrCaptchaInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newService(t, tt.c).Login(context.Background(), tt.req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v but got %v", tt.err, err)
+			}
+		})
+	}
+
+	_, err := newService(t, Config{Users: failingUsers{}}).Login(context.Background(), LoginRequest{Username: "alice", Password: "x"})
+	if err == nil || StatusCode(err) != http.StatusInternalServerError {
+		t.Fatalf("expected the store error but got %v", err)
+	}
+}
+
+func TestLoginCaptcha(t *testing.T) {
+	captchas := NewMemoryCaptchaStore()
+	s := newService(t, Config{Captchas: captchas})
+	if err := captchas.Set("id", "AbCd", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	req := LoginRequest{Username: "alice", Password: "correct horse", CaptchaID: "id", Captcha: "abcd"}
+	if _, err := s.Login(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	// A captcha can only be used once.
+	if _, err := s.Login(context.Background(), req); err != ErrCaptchaInvalid {
+		t.Fatalf("expected ErrCaptchaInvalid but got %v", err)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	s := newService(t, Config{MaxFailures: 3})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := s.Login(ctx, LoginRequest{Username: "Alice", Password: "wrong"}); err != ErrInvalidCredentials {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials but got %v", i, err)
+		}
+	}
+	if _, err := s.Login(ctx, LoginRequest{Username: "alice", Password: "correct horse"}); err != ErrLocked {
+		t.Fatalf("expected ErrLocked but got %v", err)
+	}
+}
+
+func TestMemoryLockoutStorePurge(t *testing.T) {
+	s := NewMemoryLockoutStore()
+	s.Fail("a", 5, time.Millisecond)
+	s.Fail("b", 1, time.Millisecond)
+	if s.LockedUntil("b").IsZero() {
+		t.Fatal("expected b to be locked")
+	}
+	time.Sleep(5 * time.Millisecond)
+	s.nextPurge = time.Time{}
+	s.Fail("c", 5, time.Minute)
+	if n := len(s.entries); n != 1 {
+		t.Fatalf("expected the expired entries to be purged, %d left", n)
+	}
+	if !s.LockedUntil("b").IsZero() {
+		t.Fatal("expected b to be unlocked")
+	}
+}
+
+func TestMemoryCaptchaStorePurge(t *testing.T) {
+	s := NewMemoryCaptchaStore()
+	_ = s.Set("a", "code", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	// Purged at most once a minute, the first Set already scheduled the next purge.
+	_ = s.Set("b", "code", time.Minute)
+	if n := len(s.entries); n != 2 {
+		t.Fatalf("expected no purge before a minute, %d entries", n)
+	}
+	s.nextPurge = time.Time{}
+	_ = s.Set("c", "code", time.Minute)
+	if n := len(s.entries); n != 2 {
+		t.Fatalf("expected the expired entries to be purged, %d left", n)
+	}
+	if s.Verify("a", "code") {
+		t.Fatal("expected the expired captcha to be rejected")
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	s := newService(t, Config{})
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.Post("/login", s.LoginHandler)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"username":"alice","password":"correct horse"}`, http.StatusOK},
+		{`{"username":"alice","password":"wrong"}`, http.StatusUnauthorized},
+		{`{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/login", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Fatalf("%s: expected status %d but got %d: %s", tt.body, tt.status, w.Code, w.Body)
+		}
+		if tt.status == http.StatusOK {
+			var result LoginResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || result.Token == "" {
+				t.Fatalf("unexpected body %s: %v", w.Body, err)
+			}
+		}
+	}
+}
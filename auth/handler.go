@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/kataras/iris/v12"
+)
+
+// CaptchaHandler is an Iris handler responding with a new captcha:
+// {"captcha_id": "...", "image": "data:image/png;base64,..."}.
+func (s *Service) CaptchaHandler(ctx iris.Context) {
+	id, image, err := s.NewCaptcha()
+	if err != nil {
+		ctx.Application().Logger().Errorf("auth: captcha: %v", err)
+		ctx.StopWithJSON(iris.StatusInternalServerError, iris.Map{"message": "captcha unavailable"})
+		return
+	}
+	_ = ctx.JSON(iris.Map{"captcha_id": id, "image": image.ToBase64String()})
+}
+
+// LoginHandler is an Iris handler reading a LoginRequest from the JSON body
+// and responding with the LoginResult.
+func (s *Service) LoginHandler(ctx iris.Context) {
+	var req LoginRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"message": "invalid request body"})
+		return
+	}
+	result, err := s.Login(ctx.Request().Context(), req)
+	if err != nil {
+		code := StatusCode(err)
+		if code == iris.StatusInternalServerError {
+			ctx.Application().Logger().Errorf("auth: login: %v", err)
+			ctx.StopWithJSON(code, iris.Map{"message": "login unavailable"})
+			return
+		}
+		ctx.StopWithJSON(code, iris.Map{"message": err.Error()})
+		return
+	}
+	_ = ctx.JSON(result)
+}
+
+// StatusCode maps the errors of the login flow to HTTP status codes.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrCaptchaInvalid):
+		return iris.StatusBadRequest
+	case errors.Is(err, ErrInvalidCredentials):
+		return iris.StatusUnauthorized
+	case errors.Is(err, ErrLocked):
+		return iris.StatusTooManyRequests
+	}
+	return iris.StatusInternalServerError
+}
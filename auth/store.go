@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User is the account the login flow authenticates.
+type User struct {
+	ID       string
+	Username string
+	// PasswordHash is the bcrypt hash of the password.
+	PasswordHash string
+	// Claims are added to the issued token, next to "sub", "iss", "iat" and "exp".
+	Claims map[string]interface{}
+}
+
+// UserStore looks up users by name, it's supplied by the caller.
+// FindByUsername should return nil and no error when the user does not exist.
+type UserStore interface {
+	FindByUsername(ctx context.Context, username string) (*User, error)
+}
+
+// CaptchaStore keeps the expected answers of the captchas handed out.
+type CaptchaStore interface {
+	// Set stores the answer of the captcha id until expiration.
+	Set(id, code string, expiration time.Duration) error
+	// Verify reports whether code answers the captcha id, the captcha
+	// is consumed whatever the outcome so it can only be tried once.
+	Verify(id, code string) bool
+}
+
+// LockoutStore counts the consecutive login failures of an account.
+type LockoutStore interface {
+	// LockedUntil returns the end of the current lockout, zero when not locked.
+	LockedUntil(key string) time.Time
+	// Fail records a failure and locks the key for duration once max failures are reached.
+	Fail(key string, max int, duration time.Duration)
+	// Reset clears the failures of the key after a successful login.
+	Reset(key string)
+}
+
+type captchaEntry struct {
+	code      string
+	expiresAt time.Time
+}
+
+// MemoryCaptchaStore is a CaptchaStore keeping the answers in memory,
+// expired entries are purged at most once a minute.
+type MemoryCaptchaStore struct {
+	mu        sync.Mutex
+	entries   map[string]captchaEntry
+	nextPurge time.Time
+}
+
+// NewMemoryCaptchaStore returns an empty in-memory captcha store.
+func NewMemoryCaptchaStore() *MemoryCaptchaStore {
+	return &MemoryCaptchaStore{entries: make(map[string]captchaEntry)}
+}
+
+// Set implements CaptchaStore.
+func (s *MemoryCaptchaStore) Set(id, code string, expiration time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.nextPurge) {
+		for k, e := range s.entries {
+			if now.After(e.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextPurge = now.Add(time.Minute)
+	}
+	s.entries[id] = captchaEntry{code: code, expiresAt: now.Add(expiration)}
+	return nil
+}
+
+// Verify implements CaptchaStore, the comparison ignores case.
+func (s *MemoryCaptchaStore) Verify(id, code string) bool {
+	s.mu.Lock()
+	e, ok := s.entries[id]
+	delete(s.entries, id)
+	s.mu.Unlock()
+	return ok && code != "" && time.Now().Before(e.expiresAt) && strings.EqualFold(e.code, code)
+}
+
+type lockoutEntry struct {
+	failures    int
+	lockedUntil time.Time
+	// expiresAt is when the entry is forgotten, the failures are counted
+	// over a window of the lockout duration since the last one.
+	expiresAt time.Time
+}
+
+// MemoryLockoutStore is a LockoutStore keeping the counters in memory,
+// expired entries are purged at most once a minute.
+type MemoryLockoutStore struct {
+	mu        sync.Mutex
+	entries   map[string]*lockoutEntry
+	nextPurge time.Time
+}
+
+// NewMemoryLockoutStore returns an empty in-memory lockout store.
+func NewMemoryLockoutStore() *MemoryLockoutStore {
+	return &MemoryLockoutStore{entries: make(map[string]*lockoutEntry)}
+}
+
+// LockedUntil implements LockoutStore.
+func (s *MemoryLockoutStore) LockedUntil(key string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && time.Now().Before(e.lockedUntil) {
+		return e.lockedUntil
+	}
+	return time.Time{}
+}
+
+// Fail implements LockoutStore.
+func (s *MemoryLockoutStore) Fail(key string, max int, duration time.Duration) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.nextPurge) {
+		for k, e := range s.entries {
+			if now.After(e.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextPurge = now.Add(time.Minute)
+	}
+	e, ok := s.entries[key]
+	if !ok || now.After(e.expiresAt) {
+		e = &lockoutEntry{}
+		s.entries[key] = e
+	}
+	e.failures++
+	e.expiresAt = now.Add(duration)
+	if e.failures >= max {
+		e.failures = 0
+		e.lockedUntil = e.expiresAt
+	}
+}
+
+// Reset implements LockoutStore.
+func (s *MemoryLockoutStore) Reset(key string) {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+}
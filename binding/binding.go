@@ -0,0 +1,448 @@
+// Package binding reads Iris requests into structs and validates them.
+//
+// The body is decoded according to its Content-Type, JSON or form, then the
+// fields are read from their tags:
+//
+//	type ListRequest struct {
+//		Tenant string `param:"tenant" valid:"required"`
+//		Token  string `header:"X-Token"`
+//		Page   int    `query:"page" default:"1" valid:"min=1"`
+//		Size   int    `query:"size" default:"20" valid:"max=100"`
+//		Tags   []string `form:"tag"`
+//	}
+//
+// The fields get the value of their default tag first, so that the ones
+// missing from the request keep it. The struct is then sanitized, see
+// validator.SanitizeTag, once even when the validator sanitizes too, and
+// validated by a validator.Validator with the messages in the language of
+// the request.
+package binding
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/griffin702/service/validator"
+	"github.com/kataras/iris/v12"
+)
+
+const (
+	//DefaultContextKey binding
+	DefaultContextKey = "binding"
+)
+
+// The struct tags of the sources of the fields.
+const (
+	TagForm    = "form"
+	TagQuery   = "query"
+	TagParam   = "param"
+	TagHeader  = "header"
+	TagDefault = "default"
+)
+
+// ErrNotStructPointer is returned when the value to bind is not a pointer to a struct.
+var ErrNotStructPointer = errors.New("binding: value must be a pointer to a struct")
+
+// Error is a value of the request that can not be read into its field.
+type Error struct {
+	// Source is the tag of the field or "body".
+	Source string
+	// Name is the name of the value in the source.
+	Name string
+	Err  error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("binding: invalid %s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("binding: invalid %s %q: %v", e.Source, e.Name, e.Err)
+}
+
+// Unwrap returns the error of the conversion.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Config is a struct for specifying configuration options of the Binder.
+type Config struct {
+	// Validator validates the bound structs, in the language of the request and
+	// with its context when it's a validator.ExtendedValidator.
+	// Default: validator.NewValidator()
+	Validator validator.Validator
+	// ErrorHandler writes the response when binding or validation fails.
+	// Default: OnError
+	ErrorHandler func(ctx iris.Context, err error)
+	// The key of the bound value stored in the context by Middleware.
+	// Default: "binding"
+	ContextKey string
+}
+
+// Binder reads requests into structs and validates them.
+type Binder struct {
+	Config Config
+}
+
+// New constructs a new Binder with supplied options.
+func New(cfg ...Config) *Binder {
+	var c Config
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	if c.Validator == nil {
+		c.Validator = validator.NewValidator()
+	}
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = OnError
+	}
+	if c.ContextKey == "" {
+		c.ContextKey = DefaultContextKey
+	}
+	return &Binder{Config: c}
+}
+
+// Default is the Binder of the package level functions.
+var Default = New()
+
+// Bind calls Default.Bind.
+func Bind(ctx iris.Context, ptr interface{}) error {
+	return Default.Bind(ctx, ptr)
+}
+
+// MustBind calls Default.MustBind.
+func MustBind(ctx iris.Context, ptr interface{}) bool {
+	return Default.MustBind(ctx, ptr)
+}
+
+// OnError is the default error handler: 422 with the validator.FieldErrors
+// when the validation fails, 400 with the message otherwise.
+func OnError(ctx iris.Context, err error) {
+	var fe validator.FieldErrors
+	if errors.As(err, &fe) {
+		_ = ctx.StopWithJSON(iris.StatusUnprocessableEntity, fe)
+		return
+	}
+	_ = ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"message": err.Error()})
+}
+
+// Bind reads the request into ptr, a pointer to a struct, and validates it.
+// The error is an *Error when a value can't be read, validator.FieldErrors
+// when the validation fails.
+func (b *Binder) Bind(ctx iris.Context, ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrNotStructPointer
+	}
+	// The defaults go first so that the zero values sent in the body are kept.
+	if err := bindDefaults(v.Elem()); err != nil {
+		return err
+	}
+	if err := readBody(ctx, ptr); err != nil {
+		return err
+	}
+	var form map[string][]string
+	if isForm(ctx.GetContentTypeRequested()) {
+		form = ctx.FormValues()
+	}
+	src := sources{
+		TagForm:  func(name string) []string { return form[name] },
+		TagQuery: func(name string) []string { return ctx.Request().URL.Query()[name] },
+		TagParam: func(name string) []string {
+			if p, ok := ctx.Params().Store.GetEntry(name); ok {
+				return []string{p.String()}
+			}
+			return nil
+		},
+		TagHeader: func(name string) []string { return ctx.Request().Header[http.CanonicalHeaderKey(name)] },
+	}
+	if err := bindStruct(v.Elem(), src); err != nil {
+		return err
+	}
+	// The validators with Config.Sanitize do it themselves.
+	if s, ok := b.Config.Validator.(sanitizer); !ok || !s.Sanitizes() {
+		if err := validator.Sanitize(ptr); err != nil {
+			return err
+		}
+	}
+	if v, ok := b.Config.Validator.(ctxValidator); ok {
+		return v.ValidateStructCtx(ctx.Request().Context(), ptr, validator.Locales(ctx)...)
+	}
+	return b.Config.Validator.ValidateStruct(ptr)
+}
+
+// ctxValidator is implemented by the validator.ExtendedValidator.
+type ctxValidator interface {
+	ValidateStructCtx(ctx context.Context, obj interface{}, locales ...string) error
+}
+
+// sanitizer is implemented by the validator.ExtendedValidator.
+type sanitizer interface {
+	Sanitizes() bool
+}
+
+// MustBind is Bind writing the error response with the ErrorHandler,
+// it returns false when the handler must return:
+//
+//	var req ListRequest
+//	if !binder.MustBind(ctx, &req) {
+//		return
+//	}
+func (b *Binder) MustBind(ctx iris.Context, ptr interface{}) bool {
+	if err := b.Bind(ctx, ptr); err != nil {
+		b.Config.ErrorHandler(ctx, err)
+		return false
+	}
+	return true
+}
+
+// Middleware binds every request into a new value of the type of sample,
+// a struct or a pointer to a struct, and stores the pointer to it in the
+// context for the next handlers, see Get.
+func (b *Binder) Middleware(sample interface{}) iris.Handler {
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(ErrNotStructPointer)
+	}
+	return func(ctx iris.Context) {
+		ptr := reflect.New(t).Interface()
+		if !b.MustBind(ctx, ptr) {
+			return
+		}
+		ctx.Values().Set(b.Config.ContextKey, ptr)
+		ctx.Next()
+	}
+}
+
+// Get returns the value stored by Middleware, a pointer to the struct.
+func (b *Binder) Get(ctx iris.Context) interface{} {
+	return ctx.Values().Get(b.Config.ContextKey)
+}
+
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") || strings.HasSuffix(contentType, "+json")
+}
+
+func isForm(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "multipart/form-data")
+}
+
+func readBody(ctx iris.Context, ptr interface{}) error {
+	if !isJSON(ctx.GetContentTypeRequested()) || ctx.Request().ContentLength == 0 {
+		return nil
+	}
+	if err := ctx.ReadJSON(ptr); err != nil && !iris.IsErrEmptyJSON(err) {
+		return &Error{Source: "body", Err: err}
+	}
+	return nil
+}
+
+// sources return the values of a name per tag.
+type sources map[string]func(name string) []string
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// bindDefaults sets the fields of v still zero to the value of their default tag,
+// recursing into the other struct fields. Nested pointers are only allocated
+// when one of their fields has a default.
+func bindDefaults(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		field := v.Field(i)
+
+		if def, ok := f.Tag.Lookup(TagDefault); ok {
+			if field.IsZero() {
+				if err := setValues(field, []string{def}); err != nil {
+					return &Error{Source: TagDefault, Name: f.Name, Err: err}
+				}
+			}
+			continue
+		}
+
+		st, ok := nestedStruct(f)
+		if !ok {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				nv := reflect.New(st)
+				if err := bindDefaults(nv.Elem()); err != nil {
+					return err
+				}
+				if !nv.Elem().IsZero() {
+					field.Set(nv)
+				}
+				continue
+			}
+			field = field.Elem()
+		}
+		if err := bindDefaults(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nestedStruct returns the struct type of f, when it's a struct
+// or a pointer to a struct that is not read from a single value.
+func nestedStruct(f reflect.StructField) (reflect.Type, bool) {
+	st := f.Type
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct || st == reflect.TypeOf(time.Time{}) || reflect.PtrTo(st).Implements(textUnmarshaler) {
+		return nil, false
+	}
+	return st, true
+}
+
+// bindStruct sets the tagged fields of v from the sources,
+// recursing into the untagged struct fields.
+func bindStruct(v reflect.Value, src sources) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		field := v.Field(i)
+
+		bound := false
+		for _, tag := range []string{TagParam, TagHeader, TagQuery, TagForm} {
+			name := f.Tag.Get(tag)
+			if name == "" || name == "-" {
+				continue
+			}
+			values := src[tag](name)
+			if len(values) == 0 {
+				continue
+			}
+			if err := setValues(field, values); err != nil {
+				return &Error{Source: tag, Name: name, Err: err}
+			}
+			bound = true
+			break
+		}
+		if bound {
+			continue
+		}
+
+		st, ok := nestedStruct(f)
+		if !ok {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				// Only allocate nested pointers when one of their fields is bound.
+				nv := reflect.New(st)
+				if err := bindStruct(nv.Elem(), src); err != nil {
+					return err
+				}
+				if !nv.Elem().IsZero() {
+					field.Set(nv)
+				}
+				continue
+			}
+			field = field.Elem()
+		}
+		if err := bindStruct(field, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setValues sets the field from the values, all of them for a slice,
+// the first one otherwise. Slices also accept comma separated values.
+func setValues(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && !field.Type().Implements(textUnmarshaler) && field.Type().Elem().Kind() != reflect.Uint8 {
+		if len(values) == 1 && strings.Contains(values[0], ",") {
+			values = strings.Split(values[0], ",")
+		}
+		s := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), strings.TrimSpace(value)); err != nil {
+				return err
+			}
+		}
+		field.Set(s)
+		return nil
+	}
+	return setValue(field, values[0])
+}
+
+// setValue converts s to the type of the field.
+func setValue(field reflect.Value, s string) error {
+	if field.Kind() == reflect.Ptr {
+		nv := reflect.New(field.Type().Elem())
+		if err := setValue(nv.Elem(), s); err != nil {
+			return err
+		}
+		field.Set(nv)
+		return nil
+	}
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshaler) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(s))
+			return nil
+		}
+		return setValues(field, []string{s})
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
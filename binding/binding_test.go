@@ -0,0 +1,259 @@
+package binding
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/griffin702/service/validator"
+	"github.com/kataras/iris/v12"
+)
+
+type listRequest struct {
+	Tenant string   `param:"tenant" valid:"required"`
+	Token  string   `header:"X-Token"`
+	Page   int      `query:"page" default:"1" valid:"min=1"`
+	Size   int      `query:"size" default:"20" valid:"max=100"`
+	Tags   []string `query:"tag"`
+}
+
+type settings struct {
+	Theme string `query:"theme" default:"light"`
+}
+
+type profile struct {
+	Email string `json:"email" query:"email" valid:"omitempty,email"`
+}
+
+type bodyRequest struct {
+	Name     string    `json:"name" default:"anonymous"`
+	Count    int       `json:"count" default:"5"`
+	Enabled  bool      `json:"enabled" default:"true"`
+	Profile  *profile  `json:"profile"`
+	Settings *settings `json:"settings"`
+}
+
+// sourceRequest reads the same value from every source.
+type sourceRequest struct {
+	Value string `json:"value" param:"value" header:"X-Value" query:"value" form:"value"`
+}
+
+// serve binds the request into a new value of newValue with b
+// and writes it as JSON, the routes are "/{tenant}" and "/{tenant}/{value}".
+func serve(b *Binder, newValue func() interface{}, r *http.Request) *httptest.ResponseRecorder {
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	h := func(ctx iris.Context) {
+		ptr := newValue()
+		if !b.MustBind(ctx, ptr) {
+			return
+		}
+		_ = ctx.JSON(ptr)
+	}
+	app.Any("/{tenant}", h)
+	app.Any("/{tenant}/{value}", h)
+	if err := app.Build(); err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	return w
+}
+
+func request(method, target, contentType, body string, header ...string) *http.Request {
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, rd)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	return r
+}
+
+func TestBind(t *testing.T) {
+	b := New()
+	newList := func() interface{} { return new(listRequest) }
+	newBody := func() interface{} { return new(bodyRequest) }
+	newSource := func() interface{} { return new(sourceRequest) }
+	const form = "application/x-www-form-urlencoded"
+	const js = "application/json"
+
+	tests := []struct {
+		name     string
+		newValue func() interface{}
+		r        *http.Request
+		want     interface{}
+	}{
+		{"defaults", newList, request("GET", "/acme", "", ""),
+			&listRequest{Tenant: "acme", Page: 1, Size: 20}},
+		{"query, header and param", newList, request("GET", "/acme?page=3&size=50&tag=a&tag=b", "", "", "X-Token", "t"),
+			&listRequest{Tenant: "acme", Token: "t", Page: 3, Size: 50, Tags: []string{"a", "b"}}},
+		{"comma separated", newList, request("GET", "/acme?tag=a,%20b", "", ""),
+			&listRequest{Tenant: "acme", Page: 1, Size: 20, Tags: []string{"a", "b"}}},
+		{"explicit zero query", newList, request("GET", "/acme?size=0", "", ""),
+			&listRequest{Tenant: "acme", Page: 1}},
+
+		{"body defaults", newBody, request("POST", "/acme", js, `{}`),
+			&bodyRequest{Name: "anonymous", Count: 5, Enabled: true, Settings: &settings{Theme: "light"}}},
+		{"empty body", newBody, request("POST", "/acme", js, ""),
+			&bodyRequest{Name: "anonymous", Count: 5, Enabled: true, Settings: &settings{Theme: "light"}}},
+		{"explicit zero body", newBody, request("POST", "/acme", js, `{"name":"","count":0,"enabled":false}`),
+			&bodyRequest{Count: 0, Settings: &settings{Theme: "light"}}},
+		{"nested pointer not bound", newBody, request("POST", "/acme?theme=dark", js, `{"name":"x"}`),
+			&bodyRequest{Name: "x", Count: 5, Enabled: true, Settings: &settings{Theme: "dark"}}},
+		{"nested pointer bound", newBody, request("POST", "/acme?email=a@b.c", js, `{}`),
+			&bodyRequest{Name: "anonymous", Count: 5, Enabled: true, Profile: &profile{Email: "a@b.c"}, Settings: &settings{Theme: "light"}}},
+		{"nested pointer from the body", newBody, request("POST", "/acme", js, `{"profile":{"email":"b@c.d"}}`),
+			&bodyRequest{Name: "anonymous", Count: 5, Enabled: true, Profile: &profile{Email: "b@c.d"}, Settings: &settings{Theme: "light"}}},
+
+		{"json", newSource, request("POST", "/acme", js, `{"value":"json"}`), &sourceRequest{"json"}},
+		{"form", newSource, request("POST", "/acme", form, "value=form"), &sourceRequest{"form"}},
+		{"query over json", newSource, request("POST", "/acme?value=query", js, `{"value":"json"}`), &sourceRequest{"query"}},
+		{"query over form", newSource, request("POST", "/acme?value=query", form, "value=form"), &sourceRequest{"query"}},
+		{"header over query", newSource, request("POST", "/acme?value=query", form, "value=form", "X-Value", "header"), &sourceRequest{"header"}},
+		{"param over header", newSource, request("POST", "/acme/param?value=query", js, `{"value":"json"}`, "X-Value", "header"), &sourceRequest{"param"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(b, tt.newValue, tt.r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body)
+			}
+			got := tt.newValue()
+			if err := json.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v but got %s", tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	b := New()
+	newList := func() interface{} { return new(listRequest) }
+	newBody := func() interface{} { return new(bodyRequest) }
+	tests := []struct {
+		name     string
+		newValue func() interface{}
+		r        *http.Request
+		status   int
+		contains string
+	}{
+		{"conversion", newList, request("GET", "/acme?page=abc", "", ""), http.StatusBadRequest, `binding: invalid query \"page\"`},
+		{"overflow", newList, request("GET", "/acme?page=99999999999999999999", "", ""), http.StatusBadRequest, `invalid query \"page\"`},
+		{"malformed body", newBody, request("POST", "/acme", "application/json", `{"count":"x"}`), http.StatusBadRequest, "binding: invalid body"},
+		{"validation", newList, request("GET", "/acme?page=0&size=101", "", ""), http.StatusUnprocessableEntity, `"tag":"min"`},
+		{"nested validation", newBody, request("POST", "/acme?email=nope", "application/json", `{}`), http.StatusUnprocessableEntity, `"tag":"email"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(b, tt.newValue, tt.r)
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d but got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Fatalf("expected %s in %s", tt.contains, w.Body)
+			}
+		})
+	}
+
+	// The errors returned by Bind.
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	var errs []error
+	app.Get("/{tenant}", func(ctx iris.Context) {
+		var req listRequest
+		errs = append(errs, b.Bind(ctx, &req), b.Bind(ctx, req), b.Bind(ctx, (*listRequest)(nil)))
+	})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	app.ServeHTTP(httptest.NewRecorder(), request("GET", "/acme?page=0", "", ""))
+	var fe validator.FieldErrors
+	if !errors.As(errs[0], &fe) || len(fe) != 1 || fe[0].Tag != "min" {
+		t.Fatalf("expected the FieldErrors of page but got %v", errs[0])
+	}
+	if errs[1] != ErrNotStructPointer || errs[2] != ErrNotStructPointer {
+		t.Fatalf("expected ErrNotStructPointer but got %v and %v", errs[1], errs[2])
+	}
+
+	errs = nil
+	app.ServeHTTP(httptest.NewRecorder(), request("GET", "/acme?size=x", "", ""))
+	var be *Error
+	if !errors.As(errs[0], &be) || be.Source != TagQuery || be.Name != "size" {
+		t.Fatalf("expected an *Error of the size query but got %v", errs[0])
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	b := New()
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.Get("/{tenant}", b.Middleware(&listRequest{}), func(ctx iris.Context) {
+		req := b.Get(ctx).(*listRequest)
+		ctx.Writef("%s %d", req.Tenant, req.Page)
+	})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, request("GET", "/acme?page=2", "", ""))
+	if w.Code != http.StatusOK || w.Body.String() != "acme 2" {
+		t.Fatalf("expected the bound value but got %d %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, request("GET", "/acme?page=0", "", ""))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 but got %d: %s", w.Code, w.Body)
+	}
+
+	defer func() {
+		if recover() != ErrNotStructPointer {
+			t.Fatal("expected Middleware to panic with ErrNotStructPointer")
+		}
+	}()
+	b.Middleware("not a struct")
+}
+
+type sanitizedRequest struct {
+	Name string `query:"name" sanitize:"trim,exclaim"`
+}
+
+// plainValidator hides the methods of the validator.ExtendedValidator.
+type plainValidator struct {
+	validator.Validator
+}
+
+func TestBindSanitize(t *testing.T) {
+	// exclaim is not idempotent, sanitizing twice shows.
+	validator.RegisterSanitizer("exclaim", func(s string) string { return s + "!" })
+	tests := []struct {
+		name      string
+		validator validator.Validator
+	}{
+		{"default", validator.NewValidator()},
+		{"sanitizing validator", validator.NewValidator(validator.Config{Sanitize: true})},
+		{"plain validator", plainValidator{validator.NewValidator()}},
+	}
+	for _, tt := range tests {
+		b := New(Config{Validator: tt.validator})
+		w := serve(b, func() interface{} { return new(sanitizedRequest) }, request("GET", "/acme?name=%20hi%20", "", ""))
+		if got := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || got != `{"Name":"hi!"}` {
+			t.Errorf("%s: expected the name sanitized once but got %d %s", tt.name, w.Code, got)
+		}
+	}
+}
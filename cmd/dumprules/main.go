@@ -0,0 +1,182 @@
+// Command dumprules prints the effective validation rules of the struct types
+// of a Go package, the rules of their tags merged with the overrides of a rules
+// file, in the format of validator.RuleOverrides:
+//
+//	dumprules -dir ./model -rules rules.yaml -types User,Order
+//
+// The types are read from the sources, they don't have to be registered.
+// A running service prints the same with ExtendedValidator.DumpRules.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/griffin702/service/validator"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the Go package declaring the types")
+	rules := flag.String("rules", "", "YAML or JSON file of the rule overrides")
+	tag := flag.String("tag", validator.DefaultTagName, "struct tag of the rules")
+	types := flag.String("types", "", "comma separated names of the types to print, all by default")
+	flag.Parse()
+
+	if err := run(os.Stdout, os.Stderr, *dir, *rules, *tag, *types); err != nil {
+		fmt.Fprintln(os.Stderr, "dumprules:", err)
+		os.Exit(1)
+	}
+}
+
+// run writes the effective rules to stdout and the warnings to stderr.
+func run(stdout, stderr io.Writer, dir, rulesFile, tag, types string) error {
+	structs, err := parseStructs(dir, tag)
+	if err != nil {
+		return err
+	}
+
+	var overrides validator.RuleOverrides
+	if rulesFile != "" {
+		f, err := os.Open(rulesFile)
+		if err != nil {
+			return err
+		}
+		overrides, err = validator.ReadRules(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for name, fields := range overrides {
+		s, ok := structs[name]
+		if !ok {
+			fmt.Fprintf(stderr, "dumprules: %s is not declared in %s, its rules are ignored\n", name, dir)
+			continue
+		}
+		for field, rule := range fields {
+			if _, ok := s.fields[field]; !ok {
+				return fmt.Errorf("invalid rules: %s has no field %s", name, field)
+			}
+			if merged := validator.MergeRules(s.fields[field], rule); merged != "" {
+				s.rules[field] = merged
+			} else {
+				delete(s.rules, field)
+			}
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(types, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted[name] = true
+		}
+	}
+	effective := make(validator.RuleOverrides)
+	for name, s := range structs {
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		if len(s.rules) > 0 || len(overrides[name]) > 0 || wanted[name] {
+			effective[name] = s.rules
+		}
+	}
+	for name := range wanted {
+		if _, ok := structs[name]; !ok {
+			return fmt.Errorf("%s is not declared in %s", name, dir)
+		}
+	}
+	return validator.WriteRules(stdout, effective)
+}
+
+// structInfo holds the tags of the exported fields of a struct type.
+type structInfo struct {
+	// fields are the rules of the tag of every field, empty when it has none.
+	fields map[string]string
+	// rules are the effective rules of the fields having some.
+	rules map[string]string
+}
+
+// parseStructs returns the struct types declared in the non-test files of dir.
+func parseStructs(dir, tag string) (map[string]*structInfo, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	structs := make(map[string]*structInfo)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					structs[spec.Name.Name] = newStructInfo(st, tag)
+				}
+			}
+		}
+	}
+	if len(structs) == 0 {
+		return nil, fmt.Errorf("no struct type declared in %s", dir)
+	}
+	return structs, nil
+}
+
+func newStructInfo(st *ast.StructType, tag string) *structInfo {
+	s := &structInfo{fields: make(map[string]string), rules: make(map[string]string)}
+	for _, field := range st.Fields.List {
+		var rule string
+		if field.Tag != nil {
+			if t, err := strconv.Unquote(field.Tag.Value); err == nil {
+				rule = reflect.StructTag(t).Get(tag)
+			}
+		}
+		names := field.Names
+		if len(names) == 0 {
+			// An embedded field is named after its type.
+			if name := embeddedName(field.Type); name != nil {
+				names = []*ast.Ident{name}
+			}
+		}
+		for _, name := range names {
+			if !name.IsExported() {
+				continue
+			}
+			s.fields[name.Name] = rule
+			if rule != "" {
+				s.rules[name.Name] = rule
+			}
+		}
+	}
+	return s
+}
+
+func embeddedName(expr ast.Expr) *ast.Ident {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel
+	}
+	return nil
+}
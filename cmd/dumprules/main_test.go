@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const source = `package model
+
+type User struct {
+	Name   string ` + "`valid:\"required,min=2,max=20\"`" + `
+	Email  string ` + "`valid:\"required,email\"`" + `
+	Phone  string
+	secret string ` + "`valid:\"required\"`" + `
+}
+
+type Order struct {
+	ID string ` + "`valid:\"required\"`" + `
+}
+
+type plain struct{}
+`
+
+// tempPackage writes the model package and the rules file, if any, in a
+// temporary directory and returns it, the caller removes it.
+func tempPackage(t *testing.T, rules string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "dumprules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"model.go":      source,
+		"model_test.go": "package model\n\ntype Ignored struct{ X string `valid:\"required\"` }\n",
+	}
+	if rules != "" {
+		files["rules.yaml"] = rules
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	const rules = `User:
+  Name: "max=30"
+  Email: "-required"
+  Phone: "=omitempty,e164"
+Unknown:
+  X: required
+`
+	tests := []struct {
+		name   string
+		rules  string
+		types  string
+		stdout string
+		stderr string
+	}{
+		{name: "tags", stdout: "Order:\n    ID: required\nUser:\n    Email: required,email\n    Name: required,min=2,max=20\n"},
+		{name: "overrides", rules: rules, types: "User",
+			stdout: "User:\n    Email: email\n    Name: required,min=2,max=30\n    Phone: omitempty,e164\n",
+			stderr: "dumprules: Unknown is not declared in"},
+		{name: "type without rules", types: "plain", stdout: "plain: {}\n"},
+	}
+	for _, tt := range tests {
+		dir := tempPackage(t, tt.rules)
+		rulesFile := ""
+		if tt.rules != "" {
+			rulesFile = filepath.Join(dir, "rules.yaml")
+		}
+		var stdout, stderr bytes.Buffer
+		err := run(&stdout, &stderr, dir, rulesFile, "valid", tt.types)
+		os.RemoveAll(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("%s: expected\n%s\nbut got\n%s", tt.name, tt.stdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) || (tt.stderr == "" && stderr.Len() > 0) {
+			t.Errorf("%s: expected the warning %q but got %q", tt.name, tt.stderr, stderr.String())
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		types string
+		err   string
+	}{
+		{"unknown field", "User:\n  Nope: required\n", "", "User has no field Nope"},
+		{"unexported field", "User:\n  secret: required\n", "", "User has no field secret"},
+		{"malformed rules", "User: [", "", "invalid rules"},
+		{"unknown type", "", "User,Nope", "Nope is not declared"},
+	}
+	for _, tt := range tests {
+		dir := tempPackage(t, tt.rules)
+		rulesFile := ""
+		if tt.rules != "" {
+			rulesFile = filepath.Join(dir, "rules.yaml")
+		}
+		err := run(ioutil.Discard, ioutil.Discard, dir, rulesFile, "valid", tt.types)
+		os.RemoveAll(dir)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected %q but got %v", tt.name, tt.err, err)
+		}
+	}
+
+	dir := tempPackage(t, "")
+	defer os.RemoveAll(dir)
+	if err := run(ioutil.Discard, ioutil.Discard, dir, filepath.Join(dir, "missing.yaml"), "valid", ""); err == nil {
+		t.Fatal("expected an error for a missing rules file")
+	}
+	if err := os.Remove(filepath.Join(dir, "model.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(ioutil.Discard, ioutil.Discard, dir, "", "valid", ""); err == nil {
+		t.Fatal("expected an error without struct types")
+	}
+}
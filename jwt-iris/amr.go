@@ -0,0 +1,54 @@
+package jwt
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/kataras/iris/v12"
+)
+
+// ErrAuthenticationMethods is the error value that it's returned when the
+// "amr" claim lacks one of the required authentication methods.
+var ErrAuthenticationMethods = errors.New("authentication methods are insufficient")
+
+// HasAMR reports whether the "amr" (authentication methods references) claim
+// of the token contains all the methods, e.g. HasAMR(token, "pwd", "otp").
+func HasAMR(token *jwt.Token, methods ...string) bool {
+	if token == nil {
+		return false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	var amr []string
+	switch v := claims["amr"].(type) {
+	case []string:
+		amr = v
+	case []interface{}:
+		for _, m := range v {
+			if s, ok := m.(string); ok {
+				amr = append(amr, s)
+			}
+		}
+	}
+	for _, method := range methods {
+		if !containsString(amr, method) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireAMR returns an Iris handler, to register after Serve, that only lets
+// through the requests whose token "amr" claim contains all the methods.
+func (m *Middleware) RequireAMR(methods ...string) iris.Handler {
+	return func(ctx iris.Context) {
+		token, _ := ctx.Values().Get(m.Config.ContextKey).(*jwt.Token)
+		if !HasAMR(token, methods...) {
+			m.Config.ErrorHandler(*ctx, ErrAuthenticationMethods)
+			return
+		}
+		ctx.Next()
+	}
+}
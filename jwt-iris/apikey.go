@@ -0,0 +1,310 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/kataras/iris/v12/context"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultAPIKeyHeader is the header read by the API key middleware
+// when `APIKeyConfig.Extractor` is not set.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+var (
+	// ErrAPIKeyInvalid is the error value that it's returned when
+	// the API key is unknown or does not match its stored hash.
+	ErrAPIKeyInvalid = errors.New("api key is invalid")
+
+	// ErrAPIKeyExpired is the error value that it's returned when
+	// the API key is known but expired.
+	ErrAPIKeyExpired = errors.New("api key is expired")
+
+	// ErrInsufficientScope is the error value that it's returned when
+	// the principal lacks one of the required scopes.
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// APIKey is a stored service credential. A key handed out to a caller has the
+// form "<prefix>.<secret>", only the prefix is stored in clear and used for the lookup.
+type APIKey struct {
+	// Prefix is the public part of the key used to find it in the store.
+	Prefix string
+	// Hash of the secret part, either a bcrypt hash or the hex
+	// HMAC-SHA256 returned by HashAPIKeySHA256.
+	Hash string
+	// Subject identifies the caller, it becomes the "sub" claim.
+	Subject string
+	// Scopes granted to the key, they become the space separated "scope" claim.
+	Scopes []string
+	// ExpiresAt is the expiration time of the key, zero means it never expires.
+	ExpiresAt time.Time
+}
+
+// APIKeyStore looks up API keys by prefix.
+// It should return nil and no error when the prefix is unknown.
+type APIKeyStore interface {
+	LookupAPIKey(prefix string) (*APIKey, error)
+}
+
+// MemoryAPIKeyStore is an APIKeyStore keeping the keys in memory.
+type MemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+// NewMemoryAPIKeyStore returns a store holding the given keys.
+func NewMemoryAPIKeyStore(keys ...*APIKey) *MemoryAPIKeyStore {
+	s := &MemoryAPIKeyStore{keys: make(map[string]*APIKey)}
+	for _, k := range keys {
+		s.Add(k)
+	}
+	return s
+}
+
+// Add stores the key, replacing any key with the same prefix.
+func (s *MemoryAPIKeyStore) Add(key *APIKey) {
+	s.mu.Lock()
+	s.keys[key.Prefix] = key
+	s.mu.Unlock()
+}
+
+// Remove deletes the key with the given prefix.
+func (s *MemoryAPIKeyStore) Remove(prefix string) {
+	s.mu.Lock()
+	delete(s.keys, prefix)
+	s.mu.Unlock()
+}
+
+// LookupAPIKey implements APIKeyStore.
+func (s *MemoryAPIKeyStore) LookupAPIKey(prefix string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[prefix], nil
+}
+
+// GenerateAPIKey returns a new random key in the "<prefix>.<secret>" form
+// together with its prefix and secret parts.
+func GenerateAPIKey() (key, prefix, secret string, err error) {
+	b := make([]byte, 30)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	prefix = hex.EncodeToString(b[:6])
+	secret = base64.RawURLEncoding.EncodeToString(b[6:])
+	key = prefix + "." + secret
+	return
+}
+
+// HashAPIKeySHA256 returns the hex HMAC-SHA256 of the secret part of a key
+// keyed with the pepper, use it for keys checked on every request where
+// bcrypt would be too slow.
+func HashAPIKeySHA256(secret, pepper string) string {
+	return hex.EncodeToString(apiKeyMAC(secret, pepper))
+}
+
+func apiKeyMAC(secret, pepper string) []byte {
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(secret))
+	return mac.Sum(nil)
+}
+
+// HashAPIKeyBcrypt hashes the secret part of a key with bcrypt.
+func HashAPIKeyBcrypt(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// APIKeyConfig is a struct for specifying configuration options for the API key middleware.
+type APIKeyConfig struct {
+	// The store the keys are looked up from.
+	// Default value: nil
+	Store APIKeyStore
+	// The key of the HMAC-SHA256 key hashes, unused for bcrypt hashes.
+	// Default value: ""
+	Pepper string
+	// The name of the property in the request where the principal is stored,
+	// keep it equal to the jwt middleware one to share authorization handlers
+	// Default value: "jwt"
+	ContextKey string
+	// The function that will be called when there's an error validating the key
+	// Default value: OnError
+	ErrorHandler errorHandler
+	// A boolean indicating if the credentials are required or not
+	// Default value: false
+	CredentialsOptional bool
+	// A function that extracts the key from the request
+	// Default: FromHeader(DefaultAPIKeyHeader)
+	Extractor TokenExtractor
+	// The scopes every key must carry
+	// Default: nil
+	RequiredScopes []string
+}
+
+// APIKeyMiddleware the middleware for opaque API key authentication method
+type APIKeyMiddleware struct {
+	Config APIKeyConfig
+}
+
+// NewAPIKey constructs a new API key middleware with supplied options.
+func NewAPIKey(cfg ...APIKeyConfig) *APIKeyMiddleware {
+	var c APIKeyConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+
+	if c.ContextKey == "" {
+		c.ContextKey = DefaultContextKey
+	}
+
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = OnError
+	}
+
+	if c.Extractor == nil {
+		c.Extractor = FromHeader(DefaultAPIKeyHeader)
+	}
+
+	return &APIKeyMiddleware{Config: c}
+}
+
+// FromHeader returns a function that extracts the token from the specified header
+func FromHeader(name string) TokenExtractor {
+	return func(ctx context.Context) (string, error) {
+		return ctx.GetHeader(name), nil
+	}
+}
+
+// Get returns the principal information for this client/request
+func (m *APIKeyMiddleware) Get(ctx context.Context) *jwt.Token {
+	return ctx.Values().Get(m.Config.ContextKey).(*jwt.Token)
+}
+
+// Serve the middleware's action, the principal is stored under
+// `APIKeyConfig.ContextKey` for the next handlers.
+func (m *APIKeyMiddleware) Serve(ctx context.Context) {
+	principal, err := m.CheckAPIKey(ctx)
+	if err != nil {
+		m.Config.ErrorHandler(ctx, err)
+		return
+	}
+	// ctx is a copy, the values must be set on the one calling the next handlers.
+	if principal != nil {
+		ctx.Values().Set(m.Config.ContextKey, principal)
+	}
+	// If everything ok then call next.
+	ctx.Next()
+}
+
+// CheckAPIKey the main functionality, checks for the key and returns
+// the principal, nil when the key is missing and optional.
+func (m *APIKeyMiddleware) CheckAPIKey(ctx context.Context) (*jwt.Token, error) {
+	key, err := m.Config.Extractor(ctx)
+	if err != nil {
+		logf(ctx, "Error extracting API key: %v", err)
+		return nil, err
+	}
+
+	if key == "" {
+		if m.Config.CredentialsOptional {
+			return nil, nil
+		}
+		return nil, ErrTokenMissing
+	}
+
+	principal, err := m.Verify(key)
+	if err != nil {
+		logf(ctx, "Error verifying API key %s: %v", redact(key), err)
+		return nil, err
+	}
+	return principal, nil
+}
+
+// Verify checks a "<prefix>.<secret>" key against the store and returns a
+// claims-like principal: a valid token without signature whose MapClaims hold
+// "sub", "scope", "kid", "amr" and, for expiring keys, "exp".
+func (m *APIKeyMiddleware) Verify(key string) (*jwt.Token, error) {
+	i := strings.IndexByte(key, '.')
+	if i <= 0 || i == len(key)-1 || m.Config.Store == nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	prefix, secret := key[:i], key[i+1:]
+
+	stored, err := m.Config.Store.LookupAPIKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || !m.compare(stored.Hash, secret) {
+		return nil, ErrAPIKeyInvalid
+	}
+	if !stored.ExpiresAt.IsZero() && time.Now().After(stored.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	for _, scope := range m.Config.RequiredScopes {
+		if !containsString(stored.Scopes, scope) {
+			return nil, ErrInsufficientScope
+		}
+	}
+
+	claims := jwt.MapClaims{
+		"sub":   stored.Subject,
+		"scope": strings.Join(stored.Scopes, " "),
+		"kid":   stored.Prefix,
+		"amr":   []interface{}{"apikey"},
+	}
+	if !stored.ExpiresAt.IsZero() {
+		claims["exp"] = float64(stored.ExpiresAt.Unix())
+	}
+	return &jwt.Token{
+		Header: map[string]interface{}{"typ": "apikey"},
+		Claims: claims,
+		Valid:  true,
+	}, nil
+}
+
+func (m *APIKeyMiddleware) compare(hash, secret string) bool {
+	if strings.HasPrefix(hash, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+	}
+	stored, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(apiKeyMAC(secret, m.Config.Pepper), stored)
+}
+
+// HasScope reports whether the token carries the scope in its space separated
+// "scope" claim, it works for JWTs and API key principals alike.
+func HasScope(token *jwt.Token, scope string) bool {
+	if token == nil {
+		return false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	s, _ := claims["scope"].(string)
+	return containsString(strings.Fields(s), scope)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/kataras/iris/v12"
+)
+
+const testPepper = "pepper"
+
+// apiKeyStore holds the keys "hmac.secret", "bcrypt.secret" and "expired.secret".
+func apiKeyStore(t *testing.T) *MemoryAPIKeyStore {
+	t.Helper()
+	bcryptHash, err := HashAPIKeyBcrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMemoryAPIKeyStore(
+		&APIKey{Prefix: "hmac", Hash: HashAPIKeySHA256("secret", testPepper), Subject: "svc-hmac", Scopes: []string{"read", "write"}},
+		&APIKey{Prefix: "bcrypt", Hash: bcryptHash, Subject: "svc-bcrypt", Scopes: []string{"read"},
+			ExpiresAt: time.Now().Add(time.Hour)},
+		&APIKey{Prefix: "expired", Hash: HashAPIKeySHA256("secret", testPepper), Subject: "svc-expired",
+			ExpiresAt: time.Now().Add(-time.Hour)},
+	)
+}
+
+// serveAPIKey serves a request through m, the handler behind it writes the
+// subject of the principal stored under the ContextKey.
+func serveAPIKey(m *APIKeyMiddleware, r *http.Request) *httptest.ResponseRecorder {
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.Get("/", func(ctx iris.Context) { m.Serve(*ctx) }, func(ctx iris.Context) {
+		if ctx.Values().Get(m.Config.ContextKey) == nil {
+			ctx.WriteString("anonymous")
+			return
+		}
+		ctx.WriteString(m.Get(*ctx).Claims.(jwt.MapClaims)["sub"].(string))
+	})
+	if err := app.Build(); err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	return w
+}
+
+func TestAPIKey(t *testing.T) {
+	store := apiKeyStore(t)
+	tests := []struct {
+		name   string
+		config func(*APIKeyConfig)
+		key    string
+		status int
+		body   string
+	}{
+		{name: "hmac", key: "hmac.secret", status: http.StatusOK, body: "svc-hmac"},
+		{name: "bcrypt", key: "bcrypt.secret", status: http.StatusOK, body: "svc-bcrypt"},
+		{name: "wrong secret", key: "hmac.other", status: http.StatusUnauthorized, body: ErrAPIKeyInvalid.Error()},
+		{name: "wrong bcrypt secret", key: "bcrypt.other", status: http.StatusUnauthorized, body: ErrAPIKeyInvalid.Error()},
+		{name: "wrong pepper", config: func(c *APIKeyConfig) { c.Pepper = "other" }, key: "hmac.secret", status: http.StatusUnauthorized},
+		{name: "unknown prefix", key: "nope.secret", status: http.StatusUnauthorized, body: ErrAPIKeyInvalid.Error()},
+		{name: "no secret", key: "hmac.", status: http.StatusUnauthorized, body: ErrAPIKeyInvalid.Error()},
+		{name: "no prefix", key: "secret", status: http.StatusUnauthorized, body: ErrAPIKeyInvalid.Error()},
+		{name: "expired", key: "expired.secret", status: http.StatusUnauthorized, body: ErrAPIKeyExpired.Error()},
+		{name: "missing", status: http.StatusUnauthorized, body: ErrTokenMissing.Error()},
+		{name: "optional", config: func(c *APIKeyConfig) { c.CredentialsOptional = true }, status: http.StatusOK, body: "anonymous"},
+		{name: "optional with a wrong key", config: func(c *APIKeyConfig) { c.CredentialsOptional = true }, key: "hmac.other", status: http.StatusUnauthorized},
+		{name: "required scopes", config: func(c *APIKeyConfig) { c.RequiredScopes = []string{"read", "write"} }, key: "hmac.secret", status: http.StatusOK, body: "svc-hmac"},
+		{name: "missing scope", config: func(c *APIKeyConfig) { c.RequiredScopes = []string{"read", "write"} }, key: "bcrypt.secret",
+			status: http.StatusUnauthorized, body: ErrInsufficientScope.Error()},
+		{name: "context key", config: func(c *APIKeyConfig) { c.ContextKey = "principal" }, key: "hmac.secret", status: http.StatusOK, body: "svc-hmac"},
+		{name: "extractor", config: func(c *APIKeyConfig) { c.Extractor = FromParameter("key") }, key: "hmac.secret", status: http.StatusOK, body: "svc-hmac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := APIKeyConfig{Store: store, Pepper: testPepper}
+			if tt.config != nil {
+				tt.config(&c)
+			}
+			m := NewAPIKey(c)
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.key != "" {
+				if c.Extractor != nil {
+					r = httptest.NewRequest("GET", "/?key="+tt.key, nil)
+				} else {
+					r.Header.Set(DefaultAPIKeyHeader, tt.key)
+				}
+			}
+			w := serveAPIKey(m, r)
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d but got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+				t.Fatalf("expected body %q but got %q", tt.body, w.Body)
+			}
+		})
+	}
+}
+
+func TestAPIKeyPrincipal(t *testing.T) {
+	m := NewAPIKey(APIKeyConfig{Store: apiKeyStore(t), Pepper: testPepper})
+	token, err := m.Verify("bcrypt.secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if !token.Valid || claims["sub"] != "svc-bcrypt" || claims["kid"] != "bcrypt" || claims["exp"] == nil {
+		t.Fatalf("unexpected principal %v", claims)
+	}
+	if amr := claims["amr"].([]interface{}); len(amr) != 1 || amr[0] != "apikey" {
+		t.Fatalf("unexpected amr %v", amr)
+	}
+
+	token, err = m.Verify("hmac.secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := token.Claims.(jwt.MapClaims)["exp"]; ok {
+		t.Fatal("expected no exp for a key that never expires")
+	}
+	if !HasScope(token, "read") || !HasScope(token, "write") || HasScope(token, "admin") || HasScope(token, "rea") {
+		t.Fatalf("unexpected scopes %v", token.Claims)
+	}
+	if HasScope(nil, "read") || HasScope(&jwt.Token{Claims: &jwt.StandardClaims{}}, "read") {
+		t.Fatal("expected no scope without MapClaims")
+	}
+	if !HasScope(&jwt.Token{Claims: jwt.MapClaims{"scope": "a read b"}}, "read") {
+		t.Fatal("expected the scope of a JWT")
+	}
+
+	if _, err = NewAPIKey().Verify("hmac.secret"); err != ErrAPIKeyInvalid {
+		t.Fatalf("expected ErrAPIKeyInvalid without a store but got %v", err)
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, secret, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != prefix+"."+secret || len(prefix) != 12 || secret == "" || strings.Contains(secret, ".") {
+		t.Fatalf("unexpected key %q", key)
+	}
+	m := NewAPIKey(APIKeyConfig{Store: NewMemoryAPIKeyStore(&APIKey{Prefix: prefix, Hash: HashAPIKeySHA256(secret, "")})})
+	if _, err = m.Verify(key); err != nil {
+		t.Fatal(err)
+	}
+}
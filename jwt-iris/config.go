@@ -1,6 +1,11 @@
 package jwt
 
-import "github.com/golang-jwt/jwt"
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/kataras/iris/v12/context"
+)
 
 const (
 	//DefaultContextKey jwt
@@ -39,4 +44,62 @@ type Config struct {
 	// if the token was expired, expiration error will be returned
 	// Default: false
 	Expiration bool
+	// The function that will be called when the net/http adapter fails to validate the token
+	// Default value: OnRequestError
+	RequestErrorHandler requestErrorHandler
+	// A function that extracts the token from the *http.Request, used by the net/http adapter
+	// Default: FromRequestAuthHeader (i.e., from Authorization header as bearer token)
+	RequestExtractor RequestTokenExtractor
+	// When set, the net/http adapter logs the outcome of each check through the standard logger,
+	// the Iris middleware always logs to the application logger at debug level
+	// Default: false
+	Debug bool
+	// The maximum length in bytes of a token, longer tokens are rejected before parsing
+	// Default: DefaultMaxTokenLength (8 KiB)
+	MaxTokenLength int
+	// Called after a token has been verified, with the request and the token claims
+	// Default: nil
+	OnSuccess func(r *http.Request, claims jwt.Claims)
+	// Called when the authentication fails, claims are set when the token could be parsed.
+	// They are not verified: they come from a token that failed verification and must not be trusted
+	// Default: nil
+	OnFailure func(r *http.Request, claims jwt.Claims, kind ErrorKind, err error)
+	// When set, authentication events are counted per error kind and issuer
+	// Default: nil
+	Metrics *Metrics
+	// When it returns true the request bypasses authentication, evaluated before extraction
+	// Default: nil
+	Skipper func(context.Context) bool
+	// The net/http counterpart of Skipper
+	// Default: nil
+	RequestSkipper func(*http.Request) bool
+	// Route patterns that bypass authentication, in the form "[METHOD[,METHOD...] ]/path".
+	// Each path segment may use path.Match wildcards and "**" matches any number of segments,
+	// e.g. "GET /health", "/login", "GET,POST /captcha/*", "/public/**".
+	// The patterns are compiled by New, later changes of this field are ignored
+	// Default: nil
+	Exclude []string
+	// When not empty, only the requests matching one of these route patterns are authenticated,
+	// Exclude still applies on top of it. Like Exclude, it is compiled by New
+	// Default: nil
+	Include []string
+	// The function that will return the key to decrypt compact JWE tokens (see Encrypt),
+	// when set encrypted tokens are decrypted before the nested JWS is verified
+	// Default: nil (encrypted tokens are rejected)
+	DecryptionKeyGetter DecryptionKeyGetter
+	// When set, plain JWS tokens are rejected and only encrypted tokens are accepted
+	// Default: false
+	RequireEncryption bool
+	// When set, the middleware serves several tenants: each request is mapped to one of them
+	// by TenantResolver and validated with that tenant's keys, signing method, issuer,
+	// audience and expiration policy instead of the fields above
+	// Default: nil
+	Tenants *TenantRegistry
+	// The function that maps a request to a tenant of Tenants
+	// Default: TenantByIssuer(Tenants)
+	TenantResolver TenantResolver
+	// When set, tokens bound to a key with a "cnf.jkt" claim require the "DPoP" Authorization
+	// scheme and a matching DPoP proof header. Unless it's set the "DPoP" scheme is rejected
+	// Default: nil
+	DPoP *DPoPConfig
 }
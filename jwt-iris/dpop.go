@@ -0,0 +1,275 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// DefaultDPoPMaxAge is the accepted age of a proof when `DPoPConfig.MaxAge` is not set.
+const DefaultDPoPMaxAge = 5 * time.Minute
+
+// ErrDPoPInvalid is the error value that it's returned when the DPoP proof
+// is missing, malformed or does not match the request or the access token.
+var ErrDPoPInvalid = errors.New("dpop proof is invalid")
+
+// DPoPConfig enables proof-of-possession (RFC 9449) bound tokens, see `Config.DPoP`.
+// A token carrying a "cnf.jkt" claim is only accepted together with a "DPoP"
+// proof header signed by the key of that thumbprint.
+type DPoPConfig struct {
+	// Required rejects tokens that are not bound to a key, i.e. plain bearer tokens.
+	Required bool
+	// MaxAge is how far the proof "iat" may be from now, in both directions.
+	// Default: DefaultDPoPMaxAge
+	MaxAge time.Duration
+	// ReplayCache remembers the proof "jti"s to reject replays.
+	// Default: an in-memory cache created by New
+	ReplayCache ReplayCache
+	// PublicOrigin is the "scheme://host[:port]" clients use to reach the server,
+	// set it behind a TLS terminating proxy so "htu" is checked against it
+	// instead of the origin the request was received on.
+	// Default: "" (derived from the request)
+	PublicOrigin string
+}
+
+// ReplayCache records proof identifiers until they expire.
+type ReplayCache interface {
+	// Seen records the jti until expiresAt and reports whether it was
+	// already recorded and not expired yet.
+	Seen(jti string, expiresAt time.Time) bool
+}
+
+// MemoryReplayCache is a ReplayCache keeping the identifiers in memory,
+// expired entries are purged at most once a minute.
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	nextPurge time.Time
+}
+
+// NewMemoryReplayCache returns an empty in-memory replay cache.
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{entries: make(map[string]time.Time)}
+}
+
+// Seen implements ReplayCache.
+func (c *MemoryReplayCache) Seen(jti string, expiresAt time.Time) bool {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.After(c.nextPurge) {
+		for k, exp := range c.entries {
+			if now.After(exp) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextPurge = now.Add(time.Minute)
+	}
+	if exp, ok := c.entries[jti]; ok && now.Before(exp) {
+		return true
+	}
+	c.entries[jti] = expiresAt
+	return false
+}
+
+// checkDPoP verifies the DPoP proof of the request against the access token,
+// accessToken is the token as presented by the client, encrypted or not, and
+// scheme the Authorization scheme it was sent with.
+func (m *Middleware) checkDPoP(r *http.Request, scheme, accessToken string, token *jwt.Token) error {
+	cfg := m.Config.DPoP
+	proof := r.Header.Get("DPoP")
+	if err := m.checkLength(proof); err != nil {
+		return fmt.Errorf("%w: %v", ErrDPoPInvalid, err)
+	}
+	jkt := confirmationThumbprint(token.Claims)
+	if jkt == "" {
+		if cfg.Required || proof != "" || scheme == "dpop" {
+			return fmt.Errorf("%w: access token is not bound to a key", ErrDPoPInvalid)
+		}
+		return nil
+	}
+	// RFC 9449 section 7.1, bound tokens must not be accepted as bearer tokens
+	if scheme != "dpop" {
+		return fmt.Errorf("%w: bound access token must use the DPoP scheme", ErrDPoPInvalid)
+	}
+	if proof == "" {
+		return fmt.Errorf("%w: missing DPoP header", ErrDPoPInvalid)
+	}
+
+	var thumbprint string
+	claims := jwt.MapClaims{}
+	parsed, err := jwtParser.ParseWithClaims(proof, claims, keyFunc(func(t *jwt.Token) (interface{}, error) {
+		if typ, _ := t.Header["typ"].(string); !strings.EqualFold(typ, "dpop+jwt") {
+			return nil, errors.New("typ must be dpop+jwt")
+		}
+		if strings.HasPrefix(t.Method.Alg(), "HS") {
+			return nil, errors.New("symmetric algorithms are not allowed")
+		}
+		jwk, _ := t.Header["jwk"].(map[string]interface{})
+		key, tp, err := parseJWK(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint = tp
+		return key, nil
+	}))
+	if err != nil || !parsed.Valid {
+		return fmt.Errorf("%w: %v", ErrDPoPInvalid, err)
+	}
+
+	if thumbprint != jkt {
+		return fmt.Errorf("%w: key does not match the token confirmation", ErrDPoPInvalid)
+	}
+	if htm, _ := claims["htm"].(string); htm != r.Method {
+		return fmt.Errorf("%w: htm mismatch", ErrDPoPInvalid)
+	}
+	if htu, _ := claims["htu"].(string); stripURL(htu) != requestURL(r, cfg.PublicOrigin) {
+		return fmt.Errorf("%w: htu mismatch", ErrDPoPInvalid)
+	}
+	// The proof accompanies an access token, so it must be bound to it
+	ath, _ := claims["ath"].(string)
+	if ath == "" {
+		return fmt.Errorf("%w: missing ath", ErrDPoPInvalid)
+	}
+	sum := sha256.Sum256([]byte(accessToken))
+	if ath != jwt.EncodeSegment(sum[:]) {
+		return fmt.Errorf("%w: ath mismatch", ErrDPoPInvalid)
+	}
+
+	maxAge := cfg.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultDPoPMaxAge
+	}
+	iatValue, _ := claims["iat"].(float64)
+	iat := time.Unix(int64(iatValue), 0)
+	if iatValue == 0 || time.Since(iat) > maxAge || time.Until(iat) > maxAge {
+		return fmt.Errorf("%w: iat out of range", ErrDPoPInvalid)
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return fmt.Errorf("%w: missing jti", ErrDPoPInvalid)
+	}
+	if cfg.ReplayCache.Seen(jkt+":"+jti, iat.Add(maxAge)) {
+		return fmt.Errorf("%w: proof replayed", ErrDPoPInvalid)
+	}
+	return nil
+}
+
+func confirmationThumbprint(claims jwt.Claims) string {
+	mc, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	cnf, _ := mc["cnf"].(map[string]interface{})
+	jkt, _ := cnf["jkt"].(string)
+	return jkt
+}
+
+// parseJWK returns the public key of an EC or RSA JWK and its RFC 7638 thumbprint.
+func parseJWK(jwk map[string]interface{}) (interface{}, string, error) {
+	str := func(name string) string {
+		s, _ := jwk[name].(string)
+		return s
+	}
+	num := func(name string) (*big.Int, error) {
+		b, err := jwt.DecodeSegment(str(name))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("invalid jwk member %s", name)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	var (
+		key       interface{}
+		canonical []byte
+	)
+	switch str("kty") {
+	case "EC":
+		var curve elliptic.Curve
+		switch str("crv") {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, "", errors.New("unsupported jwk curve")
+		}
+		x, err := num("x")
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := num("y")
+		if err != nil {
+			return nil, "", err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, "", errors.New("jwk point is not on curve")
+		}
+		key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		canonical, _ = json.Marshal(map[string]string{"crv": str("crv"), "kty": "EC", "x": str("x"), "y": str("y")})
+	case "RSA":
+		n, err := num("n")
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := num("e")
+		if err != nil || !e.IsInt64() {
+			return nil, "", errors.New("invalid jwk member e")
+		}
+		key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		canonical, _ = json.Marshal(map[string]string{"e": str("e"), "kty": "RSA", "n": str("n")})
+	default:
+		return nil, "", errors.New("unsupported jwk key type")
+	}
+	sum := sha256.Sum256(canonical)
+	return key, jwt.EncodeSegment(sum[:]), nil
+}
+
+// requestURL returns the request URL without query and fragment, as used for "htu",
+// the origin is taken from publicOrigin when set.
+func requestURL(r *http.Request, publicOrigin string) string {
+	if publicOrigin != "" {
+		return strings.ToLower(strings.TrimSuffix(publicOrigin, "/")) + r.URL.Path
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if r.URL.Scheme != "" {
+		scheme = r.URL.Scheme
+	}
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	return strings.ToLower(scheme+"://"+host) + r.URL.Path
+}
+
+func stripURL(u string) string {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	if i := strings.Index(u, "://"); i >= 0 {
+		rest := u[i+3:]
+		j := strings.IndexByte(rest, '/')
+		if j < 0 {
+			j = len(rest)
+		}
+		u = strings.ToLower(u[:i+3]+rest[:j]) + rest[j:]
+	}
+	return u
+}
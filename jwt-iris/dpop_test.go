@@ -0,0 +1,140 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// dpopProof signs a proof for a GET of http://example.com/ with key,
+// claims are added to the proof claims, or removed when nil.
+func dpopProof(t *testing.T, key *ecdsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	c := jwt.MapClaims{
+		"htm": "GET",
+		"htu": "http://example.com/",
+		"iat": float64(time.Now().Unix()),
+		"jti": uuid.New().String(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	proof := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+	proof.Header["typ"] = "dpop+jwt"
+	proof.Header["jwk"] = map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   jwt.EncodeSegment(key.X.Bytes()),
+		"y":   jwt.EncodeSegment(key.Y.Bytes()),
+	}
+	s, err := proof.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func ath(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return jwt.EncodeSegment(sum[:])
+}
+
+func TestDPoP(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, jkt, err := parseJWK(map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   jwt.EncodeSegment(key.X.Bytes()),
+		"y":   jwt.EncodeSegment(key.Y.Bytes()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jws := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "alice", "cnf": map[string]interface{}{"jkt": jkt}}, testSecret)
+	cek := make([]byte, 32)
+	if _, err = rand.Read(cek); err != nil {
+		t.Fatal(err)
+	}
+	jwe, err := Encrypt(jws, KeyAlgDirect, cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unbound := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "alice"}, testSecret)
+	proxied := dpopProof(t, key, jwt.MapClaims{"ath": ath(jws), "htu": "https://API.example.com/users?page=2"})
+	behindProxy := func(c *Config) { c.DPoP.PublicOrigin = "https://api.example.com/" }
+
+	tests := []struct {
+		name   string
+		config func(*Config)
+		scheme string
+		url    string
+		token  string
+		proof  string
+		ok     bool
+	}{
+		{name: "jws", token: jws, proof: dpopProof(t, key, jwt.MapClaims{"ath": ath(jws)}), ok: true},
+		{name: "jwe", token: jwe, proof: dpopProof(t, key, jwt.MapClaims{"ath": ath(jwe)}), ok: true},
+		{name: "jwe with the ath of the nested jws", token: jwe, proof: dpopProof(t, key, jwt.MapClaims{"ath": ath(jws)})},
+		{name: "missing ath", token: jws, proof: dpopProof(t, key, nil)},
+		{name: "missing proof", token: jws},
+		{name: "wrong htm", token: jws, proof: dpopProof(t, key, jwt.MapClaims{"ath": ath(jws), "htm": "POST"})},
+		{name: "missing jti", token: jws, proof: dpopProof(t, key, jwt.MapClaims{"ath": ath(jws), "jti": nil})},
+		{name: "too large", token: jws, proof: strings.Repeat("a", DefaultMaxTokenLength+1)},
+		{name: "bound token with the bearer scheme", scheme: "Bearer", token: jws, proof: dpopProof(t, key, jwt.MapClaims{"ath": ath(jws)})},
+		{name: "unbound token with the dpop scheme", token: unbound},
+		{name: "unbound token with the bearer scheme", scheme: "Bearer", token: unbound, ok: true},
+		{name: "unbound token required", config: func(c *Config) { c.DPoP.Required = true }, scheme: "Bearer", token: unbound},
+		{name: "dpop scheme not enabled", config: func(c *Config) { c.DPoP = nil }, token: jws},
+		{name: "public origin", config: behindProxy, url: "http://10.0.0.1:8080/users", token: jws, proof: proxied, ok: true},
+		{name: "public origin other path", config: behindProxy, url: "http://10.0.0.1:8080/admin", token: jws, proof: proxied},
+		{name: "behind a proxy without public origin", url: "http://10.0.0.1:8080/users", token: jws, proof: proxied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.Expiration = false
+			c.DecryptionKeyGetter = func(map[string]interface{}) (interface{}, error) { return cek, nil }
+			c.DPoP = &DPoPConfig{}
+			if tt.config != nil {
+				tt.config(&c)
+			}
+			scheme, url := tt.scheme, tt.url
+			if scheme == "" {
+				scheme = "DPoP"
+			}
+			if url == "" {
+				url = "http://example.com/"
+			}
+			r := httptest.NewRequest("GET", url, nil)
+			r.Header.Set("Authorization", scheme+" "+tt.token)
+			if tt.proof != "" {
+				r.Header.Set("DPoP", tt.proof)
+			}
+			_, err := New(c).CheckRequest(r)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && !errors.Is(err, ErrDPoPInvalid) {
+				t.Fatalf("expected ErrDPoPInvalid but got %v", err)
+			}
+		})
+	}
+}
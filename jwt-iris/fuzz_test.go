@@ -0,0 +1,85 @@
+//go:build go1.18
+// +build go1.18
+
+package jwt
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// FuzzCheckToken checks that no token is accepted unless it's signed with the
+// secret and that the logged form of a token never contains it.
+func FuzzCheckToken(f *testing.F) {
+	for _, token := range tokenCorpus {
+		f.Add(token)
+	}
+	valid, err := NewTokenWithClaims(SigningMethodHS256, jwt.MapClaims{
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(testSecret)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+
+	m := New(testConfig())
+	logf := func(string, ...interface{}) {}
+	f.Fuzz(func(t *testing.T, token string) {
+		checkRedacted(t, token)
+		parsed, _, err := m.checkToken(httptest.NewRequest("GET", "/", nil), token, logf)
+		if err != nil {
+			return
+		}
+		if parsed == nil {
+			t.Fatalf("token %q: no error and no token", token)
+		}
+		i := strings.LastIndexByte(token, '.')
+		if i < 0 || SigningMethodHS256.Verify(token[:i], token[i+1:], testSecret) != nil {
+			t.Fatalf("token %q accepted without a valid signature", token)
+		}
+	})
+}
+
+// FuzzParseAuthHeader checks that a token is only returned from a well-formed header.
+func FuzzParseAuthHeader(f *testing.F) {
+	for _, token := range tokenCorpus {
+		f.Add("Bearer " + token)
+		f.Add("DPoP " + token)
+		f.Add(token)
+	}
+	f.Fuzz(func(t *testing.T, header string) {
+		scheme, token, err := parseAuthHeader(header)
+		if err != nil || header == "" {
+			if scheme != "" || token != "" {
+				t.Fatalf("header %q: %q %q returned with %v", header, scheme, token, err)
+			}
+			return
+		}
+		if token == "" || strings.Contains(token, " ") || (scheme != "bearer" && scheme != "dpop") ||
+			!strings.EqualFold(header, scheme+" "+token) {
+			t.Fatalf("header %q: unexpected %q %q", header, scheme, token)
+		}
+		checkRedacted(t, token)
+	})
+}
+
+// checkRedacted fails when redact shows more than the first 6 bytes of token.
+func checkRedacted(t *testing.T, token string) {
+	t.Helper()
+	want := "***"
+	switch {
+	case token == "":
+		want = ""
+	case len(token) > 12:
+		want = fmt.Sprintf("%s...(%d bytes)", token[:6], len(token))
+	}
+	if redacted := redact(token); redacted != want {
+		t.Fatalf("token %q: expected %q but got %q", token, want, redacted)
+	}
+}
@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// DefaultMaxTokenLength is the token size limit used when
+// `Config.MaxTokenLength` is not set.
+const DefaultMaxTokenLength = 8 << 10
+
+var (
+	// ErrTokenTooLarge is the error value that it's returned when
+	// the extracted token exceeds `Config.MaxTokenLength`.
+	ErrTokenTooLarge = errors.New("token is too large")
+
+	// ErrTokenMalformed is the error value that it's returned when
+	// the token header can not be decoded.
+	ErrTokenMalformed = errors.New("token is malformed")
+
+	// ErrAlgNone is the error value that it's returned when
+	// the token is unsigned, i.e. its header specifies the "none" algorithm.
+	ErrAlgNone = errors.New("token signing algorithm none is not allowed")
+
+	// ErrKeyTypeMismatch is the error value that it's returned when
+	// the key returned by `Config.ValidationKeyGetter` does not belong
+	// to the algorithm family of the token header.
+	ErrKeyTypeMismatch = errors.New("validation key type does not match the token algorithm")
+
+	// ErrSigningMethod is the error value that it's returned when
+	// the token header does not specify `Config.SigningMethod`.
+	ErrSigningMethod = errors.New("unexpected signing method")
+)
+
+// checkLength rejects tokens longer than `Config.MaxTokenLength`.
+func (m *Middleware) checkLength(token string) error {
+	maxLen := m.Config.MaxTokenLength
+	if maxLen <= 0 {
+		maxLen = DefaultMaxTokenLength
+	}
+	if len(token) > maxLen {
+		return ErrTokenTooLarge
+	}
+	return nil
+}
+
+// preParse checks the header of a compact JWS before any signature work is done,
+// method is the expected signing method, if any.
+func preParse(token string, method jwt.SigningMethod) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrTokenMalformed
+	}
+	b, err := jwt.DecodeSegment(parts[0])
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err = json.Unmarshal(b, &header); err != nil {
+		return ErrTokenMalformed
+	}
+
+	if header.Alg == "" || strings.EqualFold(header.Alg, "none") {
+		return ErrAlgNone
+	}
+
+	if method != nil && method.Alg() != header.Alg {
+		return fmt.Errorf("%w: expected %s but token specified %s",
+			ErrSigningMethod,
+			method.Alg(),
+			header.Alg)
+	}
+
+	return nil
+}
+
+// keyFunc wraps `Config.ValidationKeyGetter` and rejects keys that do not
+// belong to the algorithm family of the token, so an RSA public key can never
+// be used as an HMAC secret.
+func keyFunc(getter jwt.Keyfunc) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if getter == nil {
+			return nil, errors.New("no validation key getter configured")
+		}
+		key, err := getter(token)
+		if err != nil {
+			return nil, err
+		}
+		if err = checkKeyType(token.Method.Alg(), key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+}
+
+func checkKeyType(alg string, key interface{}) error {
+	var ok bool
+	switch {
+	case strings.HasPrefix(alg, "HS"):
+		_, ok = key.([]byte)
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		_, ok = key.(*rsa.PublicKey)
+	case strings.HasPrefix(alg, "ES"):
+		_, ok = key.(*ecdsa.PublicKey)
+	case alg == "EdDSA":
+		_, ok = key.(ed25519.PublicKey)
+	}
+	if !ok {
+		return ErrKeyTypeMismatch
+	}
+	return nil
+}
+
+// redact keeps just enough of a token to correlate log lines without
+// leaking a usable credential.
+func redact(token string) string {
+	if token == "" {
+		return ""
+	}
+	if len(token) <= 12 {
+		return "***"
+	}
+	return fmt.Sprintf("%s...(%d bytes)", token[:6], len(token))
+}
@@ -0,0 +1,127 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// tokenCorpus are the seeds of the malformed tokens, none of them may be accepted.
+var tokenCorpus = []string{
+	"",
+	".",
+	"..",
+	"...",
+	"a.b",
+	"a.b.c",
+	"a.b.c.d",
+	"a.b.c.d.e.f",
+	"eyJ.eyJ.sig",
+	"e30.e30.",                              // {} header
+	"eyJhbGciOiJub25lIn0.e30.",              // alg none
+	"eyJhbGciOiJOT05FIn0.e30.",              // alg NONE
+	"eyJhbGciOiIifQ.e30.",                   // empty alg
+	"eyJhbGciOiJIUzI1NiJ9.e30.",             // no signature
+	"eyJhbGciOiJIUzI1NiJ9.bm90IGpzb24.c2ln", // claims not JSON
+	"eyJhbGciOiJSUzI1NiJ9.e30.c2ln",         // RS256 with an HMAC key
+	"bm90IGpzb24.e30.c2ln",                  // header not JSON
+	"eyJhbGciOjF9.e30.c2ln",                 // alg not a string
+	"eyJhbGciOiJIUzI1NiJ9.e30.!!!",          // signature not base64
+	"eyJhbGciOiJIUzI1NiJ9.eyJleHAiOiJ4In0.c2ln",      // exp not a number
+	"eyJhbGciOiJIUzI1NiIsImVuYyI6IkEyNTZHQ00ifQ.e30", // truncated
+	"\x00\x01\x02.\xff\xfe.\x80",
+	"ey" + strings.Repeat("A", DefaultMaxTokenLength),
+	strings.Repeat(".", DefaultMaxTokenLength+1),
+	"a.b.c.d.e",
+	"eyJhbGciOiJkaXIiLCJlbmMiOiJBMjU2R0NNIn0....",
+}
+
+func TestCheckTokenCorpus(t *testing.T) {
+	m := New(testConfig())
+	r := httptest.NewRequest("GET", "/", nil)
+	logf := func(format string, args ...interface{}) {}
+	for _, token := range tokenCorpus {
+		parsed, _, err := m.checkToken(r, token, logf)
+		if err == nil {
+			t.Errorf("token %q: expected an error, got %v", token, parsed)
+		}
+		if redacted := redact(token); len(token) > 12 && strings.Contains(redacted, token) {
+			t.Errorf("token %q: not redacted: %q", token, redacted)
+		}
+	}
+}
+
+func TestParseAuthHeaderCorpus(t *testing.T) {
+	tests := []struct {
+		header string
+		scheme string
+		token  string
+		err    bool
+	}{
+		{"", "", "", false},
+		{"Bearer abc", "bearer", "abc", false},
+		{"bearer abc", "bearer", "abc", false},
+		{"DPoP abc", "dpop", "abc", false},
+		{"Bearer", "", "", true},
+		{"Bearer ", "", "", true},
+		{"Bearer a b", "", "", true},
+		{"Basic abc", "", "", true},
+		{" Bearer abc", "", "", true},
+		{"Bearer  abc", "", "", true},
+		{"\x00 \x00", "", "", true},
+	}
+	for _, tt := range tests {
+		scheme, token, err := parseAuthHeader(tt.header)
+		if (err != nil) != tt.err || token != tt.token || scheme != tt.scheme {
+			t.Errorf("header %q: got %q, %q, %v", tt.header, scheme, token, err)
+		}
+	}
+}
+
+func TestPreParse(t *testing.T) {
+	hs256 := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "alice"}, testSecret)
+	tests := []struct {
+		token  string
+		method jwt.SigningMethod
+		err    error
+	}{
+		{hs256, nil, nil},
+		{hs256, SigningMethodHS256, nil},
+		{hs256, SigningMethodHS512, ErrSigningMethod},
+		{"eyJhbGciOiJub25lIn0.e30.", nil, ErrAlgNone},
+		{"eyJhbGciOiIifQ.e30.", nil, ErrAlgNone},
+		{"bm90IGpzb24.e30.c2ln", nil, ErrTokenMalformed},
+		{"a.b", nil, ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		if err := preParse(tt.token, tt.method); !errors.Is(err, tt.err) {
+			t.Errorf("token %q: expected %v but got %v", tt.token, tt.err, err)
+		}
+	}
+}
+
+func TestKeyTypeMismatch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signToken(t, jwt.SigningMethodRS256, jwt.MapClaims{"sub": "alice"}, key)
+	// The public key of an RSA token can never be used as an HMAC secret and the other way around.
+	c := testConfig()
+	c.SigningMethod = nil
+	m := New(c)
+	if _, _, err := m.checkToken(httptest.NewRequest("GET", "/", nil), token, func(string, ...interface{}) {}); err == nil {
+		t.Fatal("expected an error for an RS256 token verified with an HMAC secret")
+	}
+	if err := checkKeyType("HS256", &key.PublicKey); err != ErrKeyTypeMismatch {
+		t.Fatalf("expected ErrKeyTypeMismatch but got %v", err)
+	}
+	if err := checkKeyType("RS256", &key.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+}
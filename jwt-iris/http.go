@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// A function called whenever the net/http adapter encounters an error
+type requestErrorHandler func(http.ResponseWriter, *http.Request, error)
+
+// RequestTokenExtractor is the net/http counterpart of TokenExtractor,
+// it reads the token straight from the *http.Request.
+type RequestTokenExtractor func(*http.Request) (string, error)
+
+type (
+	contextKey       struct{}
+	tenantContextKey struct{}
+)
+
+// tenantKeySuffix is appended to `Config.ContextKey` to store the tenant in Iris contexts.
+const tenantKeySuffix = ".tenant"
+
+// NewContext returns a copy of ctx carrying the parsed token.
+func NewContext(ctx context.Context, token *jwt.Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, token)
+}
+
+// FromContext returns the token stored by the net/http adapter, if any.
+func FromContext(ctx context.Context) (*jwt.Token, bool) {
+	token, ok := ctx.Value(contextKey{}).(*jwt.Token)
+	return token, ok
+}
+
+// TenantFromContext returns the tenant resolved by the net/http adapter, if any.
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant, ok
+}
+
+// OnRequestError is the default error handler of the net/http adapter.
+// See `Config.RequestErrorHandler`.
+func OnRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// FromRequestAuthHeader is a "RequestTokenExtractor" that extracts
+// the JWT token from the Authorization header.
+func FromRequestAuthHeader(r *http.Request) (string, error) {
+	_, token, err := parseAuthHeader(r.Header.Get("Authorization"))
+	return token, err
+}
+
+// FromRequestParameter returns a function that extracts the token from the specified
+// query string parameter
+func FromRequestParameter(param string) RequestTokenExtractor {
+	return func(r *http.Request) (string, error) {
+		return r.URL.Query().Get(param), nil
+	}
+}
+
+// FromRequestFirst returns a function that runs multiple request token extractors
+// and takes the first token it finds
+func FromRequestFirst(extractors ...RequestTokenExtractor) RequestTokenExtractor {
+	return func(r *http.Request) (string, error) {
+		for _, ex := range extractors {
+			token, err := ex(r)
+			if err != nil {
+				return "", err
+			}
+			if token != "" {
+				return token, nil
+			}
+		}
+		return "", nil
+	}
+}
+
+func (m *Middleware) requestLogf(format string, args ...interface{}) {
+	if m.Config.Debug {
+		log.Printf(format, args...)
+	}
+}
+
+// Handler wraps next with the JWT check so the same middleware can be used
+// with plain net/http servers and routers. The parsed token is stored in the
+// request context, use FromContext to read it.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, tenant, err := m.checkRequest(r)
+		if err != nil {
+			m.Config.RequestErrorHandler(w, r, err)
+			return
+		}
+		if token != nil {
+			ctx := NewContext(r.Context(), token)
+			if tenant != nil {
+				ctx = context.WithValue(ctx, tenantContextKey{}, tenant)
+			}
+			r = r.WithContext(ctx)
+		}
+		// If everything ok then call next.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CheckRequest is the net/http counterpart of CheckJWT, it returns the
+// parsed token instead of storing it.
+func (m *Middleware) CheckRequest(r *http.Request) (*jwt.Token, error) {
+	token, _, err := m.checkRequest(r)
+	return token, err
+}
+
+func (m *Middleware) checkRequest(r *http.Request) (*jwt.Token, *Tenant, error) {
+	if !m.Config.EnableAuthOnOptions {
+		if r.Method == http.MethodOptions {
+			return nil, nil, nil
+		}
+	}
+
+	if m.Config.RequestSkipper != nil && m.Config.RequestSkipper(r) {
+		return nil, nil, nil
+	}
+	if m.skipRoute(r) {
+		m.requestLogf("Authentication skipped for %s %s", r.Method, r.URL.Path)
+		return nil, nil, nil
+	}
+
+	token, err := m.Config.RequestExtractor(r)
+	if err != nil {
+		m.requestLogf("Error extracting JWT: %v", err)
+		m.reportFailure(r, nil, ErrorKindMalformed, err)
+		return nil, nil, err
+	}
+
+	parsedToken, tenant, err := m.checkToken(r, token, m.requestLogf)
+	m.report(r, parsedToken, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parsedToken, tenant, nil
+}
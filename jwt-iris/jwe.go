@@ -0,0 +1,201 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Key management and content encryption algorithms supported for compact JWE tokens.
+const (
+	// KeyAlgDirect uses the 32 bytes []byte key directly as content encryption key.
+	KeyAlgDirect = "dir"
+	// KeyAlgRSAOAEP wraps a random content encryption key with RSAES-OAEP and SHA-1.
+	KeyAlgRSAOAEP = "RSA-OAEP"
+	// KeyAlgRSAOAEP256 wraps a random content encryption key with RSAES-OAEP and SHA-256.
+	KeyAlgRSAOAEP256 = "RSA-OAEP-256"
+	// EncA256GCM is AES-256 in Galois/Counter Mode, the only content encryption supported.
+	EncA256GCM = "A256GCM"
+)
+
+var (
+	// ErrTokenDecryption is the error value that it's returned when
+	// an encrypted token can not be decrypted, the cause is not disclosed.
+	ErrTokenDecryption = errors.New("token can not be decrypted")
+
+	// ErrTokenNotEncrypted is the error value that it's returned when
+	// `Config.RequireEncryption` is set and the token is a plain JWS.
+	ErrTokenNotEncrypted = errors.New("token must be encrypted")
+)
+
+// DecryptionKeyGetter returns the key to decrypt a JWE token given its protected header:
+// a 32 bytes []byte for "dir" or an *rsa.PrivateKey for the RSA-OAEP algorithms.
+type DecryptionKeyGetter func(header map[string]interface{}) (interface{}, error)
+
+// isEncrypted reports whether the token has the five parts of a compact JWE.
+func isEncrypted(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// Encrypt wraps a signed token (or any payload) into a compact JWE using
+// A256GCM and the given key management algorithm. The key is a 32 bytes
+// []byte for KeyAlgDirect or an *rsa.PublicKey for the RSA-OAEP algorithms.
+// The "cty" header is set to "JWT" so the result is a nested token.
+func Encrypt(payload, alg string, key interface{}, kid ...string) (string, error) {
+	var cek, encryptedKey []byte
+	switch alg {
+	case KeyAlgDirect:
+		k, ok := key.([]byte)
+		if !ok || len(k) != 32 {
+			return "", fmt.Errorf("%s requires a 32 bytes []byte key", alg)
+		}
+		cek = k
+	case KeyAlgRSAOAEP, KeyAlgRSAOAEP256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("%s requires an *rsa.PublicKey", alg)
+		}
+		cek = make([]byte, 32)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		var err error
+		if encryptedKey, err = rsa.EncryptOAEP(oaepHash(alg), rand.Reader, pub, cek, nil); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported key management algorithm %q", alg)
+	}
+
+	header := map[string]interface{}{"alg": alg, "enc": EncA256GCM, "cty": "JWT"}
+	if len(kid) > 0 && kid[0] != "" {
+		header["kid"] = kid[0]
+	}
+	b, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := jwt.EncodeSegment(b)
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(payload), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		jwt.EncodeSegment(encryptedKey),
+		jwt.EncodeSegment(iv),
+		jwt.EncodeSegment(ciphertext),
+		jwt.EncodeSegment(tag),
+	}, "."), nil
+}
+
+// Decrypt opens a compact JWE produced by Encrypt and returns its payload.
+func Decrypt(token string, getKey DecryptionKeyGetter) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return "", ErrTokenMalformed
+	}
+	segments := make([][]byte, 5)
+	for i, part := range parts {
+		b, err := jwt.DecodeSegment(part)
+		if err != nil {
+			return "", ErrTokenMalformed
+		}
+		segments[i] = b
+	}
+	var header map[string]interface{}
+	if err := json.Unmarshal(segments[0], &header); err != nil {
+		return "", ErrTokenMalformed
+	}
+	if header["enc"] != EncA256GCM || header["zip"] != nil {
+		return "", ErrTokenDecryption
+	}
+
+	if getKey == nil {
+		return "", ErrTokenDecryption
+	}
+	key, err := getKey(header)
+	if err != nil {
+		return "", err
+	}
+
+	var cek []byte
+	switch alg, _ := header["alg"].(string); alg {
+	case KeyAlgDirect:
+		k, ok := key.([]byte)
+		if !ok || len(segments[1]) != 0 {
+			return "", ErrTokenDecryption
+		}
+		cek = k
+	case KeyAlgRSAOAEP, KeyAlgRSAOAEP256:
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", ErrTokenDecryption
+		}
+		if cek, err = rsa.DecryptOAEP(oaepHash(alg), rand.Reader, priv, segments[1], nil); err != nil {
+			return "", ErrTokenDecryption
+		}
+	default:
+		return "", ErrTokenDecryption
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil || len(segments[2]) != gcm.NonceSize() {
+		return "", ErrTokenDecryption
+	}
+	plaintext, err := gcm.Open(nil, segments[2], append(segments[3], segments[4]...), []byte(parts[0]))
+	if err != nil {
+		return "", ErrTokenDecryption
+	}
+	return string(plaintext), nil
+}
+
+func oaepHash(alg string) hash.Hash {
+	if alg == KeyAlgRSAOAEP256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	if len(cek) != 32 {
+		return nil, ErrTokenDecryption
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decrypt unwraps an encrypted token so the nested JWS can be verified.
+func (m *Middleware) decrypt(token string) (string, error) {
+	if !isEncrypted(token) {
+		if m.Config.RequireEncryption {
+			return "", ErrTokenNotEncrypted
+		}
+		return token, nil
+	}
+	if m.Config.DecryptionKeyGetter == nil {
+		return "", ErrTokenDecryption
+	}
+	return Decrypt(token, m.Config.DecryptionKeyGetter)
+}
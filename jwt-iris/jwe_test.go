@@ -0,0 +1,256 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func randomKey(t *testing.T, n int) []byte {
+	t.Helper()
+	k := make([]byte, n)
+	if _, err := rand.Read(k); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func staticKey(key interface{}) DecryptionKeyGetter {
+	return func(map[string]interface{}) (interface{}, error) { return key, nil }
+}
+
+// sealDirect encrypts payload with the cek under any protected header.
+func sealDirect(t *testing.T, header map[string]interface{}, cek []byte, payload string) string {
+	t.Helper()
+	b, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := jwt.EncodeSegment(b)
+	gcm, err := newGCM(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := randomKey(t, gcm.NonceSize())
+	sealed := gcm.Seal(nil, iv, []byte(payload), []byte(protected))
+	n := len(sealed) - gcm.Overhead()
+	return strings.Join([]string{protected, "", jwt.EncodeSegment(iv),
+		jwt.EncodeSegment(sealed[:n]), jwt.EncodeSegment(sealed[n:])}, ".")
+}
+
+func TestJWERoundTrip(t *testing.T) {
+	cek := randomKey(t, 32)
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		alg    string
+		encKey interface{}
+		decKey interface{}
+	}{
+		{KeyAlgDirect, cek, cek},
+		{KeyAlgRSAOAEP, &priv.PublicKey, priv},
+		{KeyAlgRSAOAEP256, &priv.PublicKey, priv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			token, err := Encrypt("payload", tt.alg, tt.encKey, "kid-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isEncrypted(token) {
+				t.Fatalf("expected a compact JWE but got %q", token)
+			}
+			var header map[string]interface{}
+			if err := json.Unmarshal(mustDecode(t, strings.Split(token, ".")[0]), &header); err != nil {
+				t.Fatal(err)
+			}
+			if header["alg"] != tt.alg || header["enc"] != EncA256GCM || header["cty"] != "JWT" || header["kid"] != "kid-1" {
+				t.Fatalf("unexpected header %v", header)
+			}
+			payload, err := Decrypt(token, staticKey(tt.decKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload != "payload" {
+				t.Fatalf("expected the payload but got %q", payload)
+			}
+		})
+	}
+}
+
+func mustDecode(t *testing.T, seg string) []byte {
+	t.Helper()
+	b, err := jwt.DecodeSegment(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestJWETampering(t *testing.T) {
+	cek := randomKey(t, 32)
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		alg    string
+		encKey interface{}
+		decKey interface{}
+	}{
+		{KeyAlgDirect, cek, cek},
+		{KeyAlgRSAOAEP256, &priv.PublicKey, priv},
+	} {
+		token, err := Encrypt("payload", tt.alg, tt.encKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := strings.Split(token, ".")
+		for i := range parts {
+			// Flip a bit of the decoded segment, or add a byte to an empty one.
+			b := mustDecode(t, parts[i])
+			if len(b) == 0 {
+				b = []byte{0}
+			} else {
+				b[len(b)/2] ^= 1
+			}
+			tampered := append([]string(nil), parts...)
+			tampered[i] = jwt.EncodeSegment(b)
+			_, err := Decrypt(strings.Join(tampered, "."), staticKey(tt.decKey))
+			if !errors.Is(err, ErrTokenDecryption) && !errors.Is(err, ErrTokenMalformed) {
+				t.Errorf("%s: segment %d tampered: expected a decryption error but got %v", tt.alg, i, err)
+			}
+		}
+	}
+}
+
+func TestJWEErrors(t *testing.T) {
+	cek := randomKey(t, 32)
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, err := Encrypt("payload", KeyAlgDirect, cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oaep, err := Encrypt("payload", KeyAlgRSAOAEP, &priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getterErr := errors.New("no key")
+
+	tests := []struct {
+		name  string
+		token string
+		key   DecryptionKeyGetter
+		err   error
+	}{
+		{"wrong dir key", direct, staticKey(randomKey(t, 32)), ErrTokenDecryption},
+		{"short dir key", direct, staticKey(cek[:16]), ErrTokenDecryption},
+		{"rsa key for dir", direct, staticKey(priv), ErrTokenDecryption},
+		{"wrong rsa key", oaep, staticKey(other), ErrTokenDecryption},
+		{"dir key for rsa", oaep, staticKey(cek), ErrTokenDecryption},
+		{"getter error", direct, func(map[string]interface{}) (interface{}, error) { return nil, getterErr }, getterErr},
+		{"no getter", direct, nil, ErrTokenDecryption},
+		{"zip", sealDirect(t, map[string]interface{}{"alg": "dir", "enc": "A256GCM", "zip": "DEF"}, cek, "payload"), staticKey(cek), ErrTokenDecryption},
+		{"other enc", sealDirect(t, map[string]interface{}{"alg": "dir", "enc": "A128GCM"}, cek, "payload"), staticKey(cek), ErrTokenDecryption},
+		{"other alg", sealDirect(t, map[string]interface{}{"alg": "A256KW", "enc": "A256GCM"}, cek, "payload"), staticKey(cek), ErrTokenDecryption},
+		{"four parts", "a.b.c.d", staticKey(cek), ErrTokenMalformed},
+		{"bad base64", "!.b.c.d.e", staticKey(cek), ErrTokenMalformed},
+		{"header not JSON", "bm90IGpzb24....", staticKey(cek), ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		if _, err := Decrypt(tt.token, tt.key); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.err, err)
+		}
+	}
+	// The same header without zip is accepted, the rejections above come from the header checks.
+	if _, err := Decrypt(sealDirect(t, map[string]interface{}{"alg": "dir", "enc": "A256GCM"}, cek, "payload"), staticKey(cek)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		alg string
+		key interface{}
+	}{
+		{KeyAlgDirect, cek[:16]},
+		{KeyAlgDirect, &priv.PublicKey},
+		{KeyAlgRSAOAEP, cek},
+		{KeyAlgRSAOAEP256, priv},
+		{"A256KW", cek},
+	} {
+		if _, err := Encrypt("payload", tt.alg, tt.key); err == nil {
+			t.Errorf("%s with %T: expected an error", tt.alg, tt.key)
+		}
+	}
+}
+
+func TestJWEMiddleware(t *testing.T) {
+	cek := randomKey(t, 32)
+	jws := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}, testSecret)
+	jwe, err := Encrypt(jws, KeyAlgDirect, cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongKey, err := Encrypt(jws, KeyAlgDirect, randomKey(t, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "mallory"}, []byte("other"))
+	forgedJWE, err := Encrypt(forged, KeyAlgDirect, cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config func(*Config)
+		token  string
+		status int
+		body   string
+	}{
+		{name: "encrypted", token: jwe, status: http.StatusOK, body: "alice"},
+		{name: "plain", token: jws, status: http.StatusOK, body: "alice"},
+		{name: "required and encrypted", config: func(c *Config) { c.RequireEncryption = true }, token: jwe, status: http.StatusOK, body: "alice"},
+		{name: "required and plain", config: func(c *Config) { c.RequireEncryption = true }, token: jws, status: http.StatusUnauthorized, body: ErrTokenNotEncrypted.Error()},
+		{name: "wrong key", token: wrongKey, status: http.StatusUnauthorized, body: ErrTokenDecryption.Error()},
+		{name: "nested signature", token: forgedJWE, status: http.StatusUnauthorized},
+		{name: "no getter", config: func(c *Config) { c.DecryptionKeyGetter = nil }, token: jwe, status: http.StatusUnauthorized, body: ErrTokenDecryption.Error()},
+	}
+	for _, a := range adapters {
+		for _, tt := range tests {
+			t.Run(a.name+"/"+tt.name, func(t *testing.T) {
+				c := testConfig()
+				c.DecryptionKeyGetter = staticKey(cek)
+				if tt.config != nil {
+					tt.config(&c)
+				}
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+				w := httptest.NewRecorder()
+				a.serve(New(c), w, r)
+				if w.Code != tt.status {
+					t.Fatalf("expected status %d but got %d: %s", tt.status, w.Code, w.Body)
+				}
+				if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+					t.Fatalf("expected body %q but got %q", tt.body, w.Body)
+				}
+			})
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/golang-jwt/jwt"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/context"
+	"net/http"
 	"strings"
 	"time"
 )
@@ -61,6 +62,8 @@ type TokenExtractor func(context.Context) (string, error)
 // Middleware the middleware for JSON Web tokens authentication method
 type Middleware struct {
 	Config Config
+
+	include, exclude []routePattern
 }
 
 // OnError is the default error handler.
@@ -98,7 +101,25 @@ func New(cfg ...Config) *Middleware {
 		c.Extractor = FromAuthHeader
 	}
 
-	return &Middleware{Config: c}
+	if c.RequestErrorHandler == nil {
+		c.RequestErrorHandler = OnRequestError
+	}
+
+	if c.RequestExtractor == nil {
+		c.RequestExtractor = FromRequestAuthHeader
+	}
+
+	if c.DPoP != nil && c.DPoP.ReplayCache == nil {
+		dpop := *c.DPoP
+		dpop.ReplayCache = NewMemoryReplayCache()
+		c.DPoP = &dpop
+	}
+
+	return &Middleware{
+		Config:  c,
+		include: compileRoutes(c.Include),
+		exclude: compileRoutes(c.Exclude),
+	}
 }
 
 func logf(ctx context.Context, format string, args ...interface{}) {
@@ -110,12 +131,22 @@ func (m *Middleware) Get(ctx context.Context) *jwt.Token {
 	return ctx.Values().Get(m.Config.ContextKey).(*jwt.Token)
 }
 
+// GetTenant returns the tenant resolved for this client/request,
+// nil unless `Config.Tenants` is set
+func (m *Middleware) GetTenant(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Values().Get(m.Config.ContextKey + tenantKeySuffix).(*Tenant)
+	return tenant
+}
+
 // Serve the middleware's action
 func (m *Middleware) Serve(ctx context.Context) {
-	if err := m.CheckJWT(ctx); err != nil {
+	token, tenant, err := m.checkJWT(ctx)
+	if err != nil {
 		m.Config.ErrorHandler(ctx, err)
 		return
 	}
+	// ctx is a copy, the values must be set on the one calling the next handlers.
+	m.store(&ctx, token, tenant)
 	// If everything ok then call next.
 	ctx.Next()
 }
@@ -123,18 +154,38 @@ func (m *Middleware) Serve(ctx context.Context) {
 // FromAuthHeader is a "TokenExtractor" that takes a give context and extracts
 // the JWT token from the Authorization header.
 func FromAuthHeader(ctx context.Context) (string, error) {
-	authHeader := ctx.GetHeader("Authorization")
+	_, token, err := parseAuthHeader(ctx.GetHeader("Authorization"))
+	return token, err
+}
+
+// parseAuthHeader returns the lower cased scheme, "bearer" or "dpop", and the token.
+func parseAuthHeader(authHeader string) (scheme, token string, err error) {
 	if authHeader == "" {
-		return "", nil // No error, just no token
+		return "", "", nil // No error, just no token
 	}
 
 	// TODO: Make this a bit more robust, parsing-wise
 	authHeaderParts := strings.Split(authHeader, " ")
-	if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
-		return "", fmt.Errorf("Authorization header format must be Bearer {token}")
+	if len(authHeaderParts) != 2 || authHeaderParts[1] == "" {
+		return "", "", fmt.Errorf("Authorization header format must be Bearer {token}")
+	}
+	// DPoP bound tokens are sent with their own scheme, see `Config.DPoP`
+	scheme = strings.ToLower(authHeaderParts[0])
+	if scheme != "bearer" && scheme != "dpop" {
+		return "", "", fmt.Errorf("Authorization header format must be Bearer {token}")
 	}
 
-	return authHeaderParts[1], nil
+	return scheme, authHeaderParts[1], nil
+}
+
+// authScheme returns the scheme the token was sent with in the Authorization
+// header, empty when it was extracted from somewhere else.
+func authScheme(r *http.Request, token string) string {
+	scheme, t, err := parseAuthHeader(r.Header.Get("Authorization"))
+	if err != nil || t != token {
+		return ""
+	}
+	return scheme
 }
 
 // FromParameter returns a function that extracts the token from the specified
@@ -180,75 +231,156 @@ var jwtParser = new(jwt.Parser)
 
 // CheckJWT the main functionality, checks for token
 func (m *Middleware) CheckJWT(ctx context.Context) error {
+	token, tenant, err := m.checkJWT(ctx)
+	if err != nil {
+		return err
+	}
+	m.store(&ctx, token, tenant)
+	return nil
+}
+
+// store sets the user property, and the tenant if any, in the context.
+func (m *Middleware) store(ctx *context.Context, token *jwt.Token, tenant *Tenant) {
+	if token == nil {
+		return
+	}
+	ctx.Values().Set(m.Config.ContextKey, token)
+	if tenant != nil {
+		ctx.Values().Set(m.Config.ContextKey+tenantKeySuffix, tenant)
+	}
+}
+
+func (m *Middleware) checkJWT(ctx context.Context) (*jwt.Token, *Tenant, error) {
 	if !m.Config.EnableAuthOnOptions {
 		if ctx.Method() == iris.MethodOptions {
-			return nil
+			return nil, nil, nil
 		}
 	}
 
+	if m.Config.Skipper != nil && m.Config.Skipper(ctx) {
+		return nil, nil, nil
+	}
+	if m.skipRoute(ctx.Request()) {
+		logf(ctx, "Authentication skipped for %s %s", ctx.Method(), ctx.Path())
+		return nil, nil, nil
+	}
+
 	// Use the specified token extractor to extract a token from the request
 	token, err := m.Config.Extractor(ctx)
 
 	// If debugging is turned on, log the outcome
 	if err != nil {
 		logf(ctx, "Error extracting JWT: %v", err)
-		return err
+		m.reportFailure(ctx.Request(), nil, ErrorKindMalformed, err)
+		return nil, nil, err
+	}
+
+	parsedToken, tenant, err := m.checkToken(ctx.Request(), token, func(format string, args ...interface{}) {
+		logf(ctx, format, args...)
+	})
+	m.report(ctx.Request(), parsedToken, err)
+	if err != nil {
+		return nil, nil, err
 	}
+	return parsedToken, tenant, nil
+}
 
-	logf(ctx, "Token extracted: %s", token)
+// checkToken runs the verification shared by the Iris middleware and the
+// net/http adapter on an already extracted token. A nil token with a nil
+// error means the credentials were optional and not present. On failure the
+// token is still returned when it could be parsed, so its claims can be reported.
+// The tenant is nil unless `Config.Tenants` is set.
+func (m *Middleware) checkToken(r *http.Request, token string, logf func(format string, args ...interface{})) (*jwt.Token, *Tenant, error) {
+	logf("Token extracted: %s", redact(token))
 
 	// If the token is empty...
 	if token == "" {
 		// Check if it was required
 		if m.Config.CredentialsOptional {
-			logf(ctx, "No credentials found (CredentialsOptional=true)")
+			logf("No credentials found (CredentialsOptional=true)")
 			// No error, just no token (and that is ok given that CredentialsOptional is true)
-			return nil
+			return nil, nil, nil
 		}
 
 		// If we get here, the required token is missing
-		logf(ctx, "Error: No credentials found (CredentialsOptional=false)")
-		return ErrTokenMissing
+		logf("Error: No credentials found (CredentialsOptional=false)")
+		return nil, nil, ErrTokenMissing
 	}
 
-	// Now parse the token
+	if err := m.checkLength(token); err != nil {
+		logf("Error validating token size: %v", err)
+		return nil, nil, err
+	}
 
-	parsedToken, err := jwtParser.Parse(token, m.Config.ValidationKeyGetter)
-	// Check if there was an error in parsing...
+	scheme := authScheme(r, token)
+	if scheme == "dpop" && m.Config.DPoP == nil {
+		logf("Error: DPoP scheme used but DPoP is not enabled")
+		return nil, nil, fmt.Errorf("%w: DPoP scheme is not enabled", ErrDPoPInvalid)
+	}
+
+	// Unwrap encrypted tokens, the nested JWS is verified below,
+	// token is kept as presented for the DPoP "ath" check
+	jws, err := m.decrypt(token)
 	if err != nil {
-		logf(ctx, "Error parsing token: %v", err)
-		return err
+		logf("Error decrypting token: %v", err)
+		return nil, nil, err
 	}
 
-	if m.Config.SigningMethod != nil && m.Config.SigningMethod.Alg() != parsedToken.Header["alg"] {
-		err := fmt.Errorf("Expected %s signing method but token specified %s",
-			m.Config.SigningMethod.Alg(),
-			parsedToken.Header["alg"])
-		logf(ctx, "Error validating token algorithm: %v", err)
-		return err
+	keyGetter, method, expiration := m.Config.ValidationKeyGetter, m.Config.SigningMethod, m.Config.Expiration
+	tenant, err := m.resolveTenant(r, jws)
+	if err != nil {
+		logf("Error resolving tenant: %v", err)
+		return nil, nil, err
+	}
+	if tenant != nil {
+		keyGetter, method, expiration = tenant.keyGetter(), tenant.SigningMethod, tenant.Expiration
+	}
+
+	// Reject unsigned or unexpected tokens before parsing
+	if err := preParse(jws, method); err != nil {
+		logf("Error validating token header: %v", err)
+		return nil, tenant, err
+	}
+
+	// Now parse the token
+
+	parsedToken, err := jwtParser.Parse(jws, keyFunc(keyGetter))
+	// Check if there was an error in parsing...
+	if err != nil {
+		logf("Error parsing token: %v", err)
+		return parsedToken, tenant, err
 	}
 
 	// Check if the parsed token is valid...
 	if !parsedToken.Valid {
-		logf(ctx, "Token is invalid")
-		m.Config.ErrorHandler(ctx, ErrTokenInvalid)
-		return ErrTokenInvalid
+		logf("Token is invalid")
+		return parsedToken, tenant, ErrTokenInvalid
 	}
 
-	if m.Config.Expiration {
+	if expiration {
 		if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok {
 			if expired := claims.VerifyExpiresAt(time.Now().Unix(), true); !expired {
-				logf(ctx, "Token is expired")
-				return ErrTokenExpired
+				logf("Token is expired")
+				return parsedToken, tenant, ErrTokenExpired
 			}
 		}
 	}
 
-	logf(ctx, "JWT: %v", parsedToken)
+	if tenant != nil {
+		if err := checkTenantClaims(tenant, parsedToken.Claims); err != nil {
+			logf("Error validating claims of tenant %s: %v", tenant.ID, err)
+			return parsedToken, tenant, err
+		}
+	}
 
-	// If we get here, everything worked and we can set the
-	// user property in context.
-	ctx.Values().Set(m.Config.ContextKey, parsedToken)
+	if m.Config.DPoP != nil {
+		if err := m.checkDPoP(r, scheme, token, parsedToken); err != nil {
+			logf("Error validating DPoP proof: %v", err)
+			return parsedToken, tenant, err
+		}
+	}
 
-	return nil
+	logf("JWT: %v", parsedToken.Header)
+
+	return parsedToken, tenant, nil
 }
@@ -0,0 +1,136 @@
+package jwt
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/kataras/iris/v12"
+)
+
+var testSecret = []byte("My Secret")
+
+func testConfig() Config {
+	return Config{
+		ValidationKeyGetter: func(*jwt.Token) (interface{}, error) { return testSecret, nil },
+		SigningMethod:       SigningMethodHS256,
+		Expiration:          true,
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims, key interface{}) string {
+	t.Helper()
+	token, err := NewTokenWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// adapter serves a request through one of the two front ends of a Middleware,
+// the handler behind it writes the "sub" claim reported by OnSuccess.
+type adapter struct {
+	name  string
+	serve func(m *Middleware, w http.ResponseWriter, r *http.Request)
+}
+
+var adapters = []adapter{
+	{"iris", func(m *Middleware, w http.ResponseWriter, r *http.Request) {
+		sub := onSuccess(m)
+		app := iris.New()
+		app.Logger().SetLevel("disable")
+		app.Any("/{p:path}", func(ctx iris.Context) { m.Serve(*ctx) }, func(ctx iris.Context) {
+			ctx.WriteString(*sub)
+		})
+		if err := app.Build(); err != nil {
+			panic(err)
+		}
+		app.ServeHTTP(w, r)
+	}},
+	{"net/http", func(m *Middleware, w http.ResponseWriter, r *http.Request) {
+		sub := onSuccess(m)
+		m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := FromContext(r.Context()); !ok && *sub != "anonymous" {
+				panic("token not stored in the request context")
+			}
+			_, _ = w.Write([]byte(*sub))
+		})).ServeHTTP(w, r)
+	}},
+}
+
+// onSuccess records the "sub" claim of the verified token, "anonymous" until then.
+func onSuccess(m *Middleware) *string {
+	sub := "anonymous"
+	m.Config.OnSuccess = func(r *http.Request, claims jwt.Claims) {
+		sub, _ = claims.(jwt.MapClaims)["sub"].(string)
+	}
+	return &sub
+}
+
+func TestAdapters(t *testing.T) {
+	valid := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}, testSecret)
+	expired := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}, testSecret)
+	wrongKey := signToken(t, SigningMethodHS256, jwt.MapClaims{"sub": "alice"}, []byte("other"))
+	hs512 := signToken(t, SigningMethodHS512, jwt.MapClaims{"sub": "alice"}, testSecret)
+	none := signToken(t, jwt.SigningMethodNone, jwt.MapClaims{"sub": "alice"}, jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name   string
+		config func(*Config)
+		method string
+		path   string
+		auth   string
+		status int
+		body   string
+	}{
+		{name: "valid", auth: "Bearer " + valid, status: http.StatusOK, body: "alice"},
+		{name: "missing", status: http.StatusUnauthorized, body: ErrTokenMissing.Error()},
+		{name: "optional", config: func(c *Config) { c.CredentialsOptional = true }, status: http.StatusOK, body: "anonymous"},
+		{name: "bad scheme", auth: "Basic " + valid, status: http.StatusUnauthorized},
+		{name: "bad format", auth: "Bearer", status: http.StatusUnauthorized},
+		{name: "expired", auth: "Bearer " + expired, status: http.StatusUnauthorized},
+		{name: "wrong key", auth: "Bearer " + wrongKey, status: http.StatusUnauthorized},
+		{name: "signing method", auth: "Bearer " + hs512, status: http.StatusUnauthorized},
+		{name: "alg none", auth: "Bearer " + none, status: http.StatusUnauthorized, body: ErrAlgNone.Error()},
+		{name: "too large", config: func(c *Config) { c.MaxTokenLength = 16 }, auth: "Bearer " + valid, status: http.StatusUnauthorized, body: ErrTokenTooLarge.Error()},
+		{name: "options", method: http.MethodOptions, status: http.StatusOK, body: "anonymous"},
+		{name: "excluded", config: func(c *Config) { c.Exclude = []string{"GET /health"} }, path: "/health", status: http.StatusOK, body: "anonymous"},
+		{name: "excluded other method", config: func(c *Config) { c.Exclude = []string{"GET /health"} }, method: http.MethodPost, path: "/health", status: http.StatusUnauthorized},
+	}
+
+	for _, a := range adapters {
+		for _, tt := range tests {
+			t.Run(a.name+"/"+tt.name, func(t *testing.T) {
+				c := testConfig()
+				if tt.config != nil {
+					tt.config(&c)
+				}
+				method, path := tt.method, tt.path
+				if method == "" {
+					method = http.MethodGet
+				}
+				if path == "" {
+					path = "/"
+				}
+				r := httptest.NewRequest(method, path, nil)
+				if tt.auth != "" {
+					r.Header.Set("Authorization", tt.auth)
+				}
+				w := httptest.NewRecorder()
+				a.serve(New(c), w, r)
+
+				if w.Code != tt.status {
+					t.Fatalf("expected status %d but got %d: %s", tt.status, w.Code, w.Body)
+				}
+				body, _ := ioutil.ReadAll(w.Body)
+				if tt.body != "" && strings.TrimSpace(string(body)) != tt.body {
+					t.Fatalf("expected body %q but got %q", tt.body, body)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/kataras/iris/v12"
+)
+
+// ErrorKind classifies why an authentication attempt failed,
+// it is passed to `Config.OnFailure` and used as a metrics label.
+type ErrorKind string
+
+// The error kinds reported by the middleware.
+const (
+	ErrorKindMissing      ErrorKind = "missing"
+	ErrorKindMalformed    ErrorKind = "malformed"
+	ErrorKindTooLarge     ErrorKind = "too_large"
+	ErrorKindDecryption   ErrorKind = "decryption"
+	ErrorKindAlgorithm    ErrorKind = "algorithm"
+	ErrorKindBadSignature ErrorKind = "bad_signature"
+	ErrorKindExpired      ErrorKind = "expired"
+	ErrorKindUnverifiable ErrorKind = "unverifiable"
+	ErrorKindTenant       ErrorKind = "tenant"
+	ErrorKindDPoP         ErrorKind = "dpop"
+	ErrorKindInvalid      ErrorKind = "invalid"
+)
+
+// KindOf returns the ErrorKind of an error returned by CheckJWT or CheckRequest.
+func KindOf(err error) ErrorKind {
+	switch {
+	case errors.Is(err, ErrTokenMissing):
+		return ErrorKindMissing
+	case errors.Is(err, ErrTokenMalformed):
+		return ErrorKindMalformed
+	case errors.Is(err, ErrTokenTooLarge):
+		return ErrorKindTooLarge
+	case errors.Is(err, ErrTokenDecryption), errors.Is(err, ErrTokenNotEncrypted):
+		return ErrorKindDecryption
+	case errors.Is(err, ErrAlgNone), errors.Is(err, ErrKeyTypeMismatch), errors.Is(err, ErrSigningMethod):
+		return ErrorKindAlgorithm
+	case errors.Is(err, ErrTokenExpired):
+		return ErrorKindExpired
+	case errors.Is(err, ErrTenantUnknown), errors.Is(err, ErrTokenIssuer), errors.Is(err, ErrTokenAudience):
+		return ErrorKindTenant
+	case errors.Is(err, ErrDPoPInvalid):
+		return ErrorKindDPoP
+	}
+
+	var vErr *jwt.ValidationError
+	if errors.As(err, &vErr) {
+		switch {
+		case vErr.Errors&jwt.ValidationErrorMalformed != 0:
+			return ErrorKindMalformed
+		case vErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+			if vErr.Inner != nil {
+				if kind := KindOf(vErr.Inner); kind != ErrorKindInvalid {
+					return kind
+				}
+			}
+			return ErrorKindUnverifiable
+		case vErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+			return ErrorKindBadSignature
+		case vErr.Errors&jwt.ValidationErrorExpired != 0:
+			return ErrorKindExpired
+		}
+	}
+	return ErrorKindInvalid
+}
+
+// report invokes the audit hooks and updates the metrics after a check.
+// Nothing is reported when there was neither a token nor an error,
+// i.e. the request was skipped or its credentials were optional.
+func (m *Middleware) report(r *http.Request, token *jwt.Token, err error) {
+	if err != nil {
+		m.reportFailure(r, token, KindOf(err), err)
+		return
+	}
+	if token == nil {
+		return
+	}
+	if m.Config.Metrics != nil {
+		m.Config.Metrics.success(issuerOf(token.Claims))
+	}
+	if m.Config.OnSuccess != nil {
+		m.Config.OnSuccess(r, token.Claims)
+	}
+}
+
+func (m *Middleware) reportFailure(r *http.Request, token *jwt.Token, kind ErrorKind, err error) {
+	var claims jwt.Claims
+	if token != nil {
+		claims = token.Claims
+	}
+	if m.Config.Metrics != nil {
+		m.Config.Metrics.failure(kind, issuerOf(claims))
+	}
+	if m.Config.OnFailure != nil {
+		m.Config.OnFailure(r, claims, kind, err)
+	}
+}
+
+func issuerOf(claims jwt.Claims) string {
+	switch c := claims.(type) {
+	case jwt.MapClaims:
+		iss, _ := c["iss"].(string)
+		return iss
+	case *jwt.StandardClaims:
+		return c.Issuer
+	}
+	return ""
+}
+
+// UnknownIssuer is the issuer label of the failures whose issuer is not
+// one of the issuers given to NewMetrics.
+const UnknownIssuer = "unknown"
+
+type failureKey struct {
+	kind   ErrorKind
+	issuer string
+}
+
+// Metrics is a set of counters of authentication events,
+// set it to `Config.Metrics` and expose it with Serve or ServeHTTP.
+type Metrics struct {
+	mu        sync.Mutex
+	issuers   map[string]bool
+	successes map[string]uint64
+	failures  map[failureKey]uint64
+}
+
+// NewMetrics returns an empty counter set. The failures are labeled with
+// the issuer of the token only when it is one of issuers, UnknownIssuer
+// otherwise, since the claims of a token that failed verification are
+// chosen by the client.
+func NewMetrics(issuers ...string) *Metrics {
+	m := &Metrics{
+		issuers:   make(map[string]bool, len(issuers)),
+		successes: make(map[string]uint64),
+		failures:  make(map[failureKey]uint64),
+	}
+	for _, iss := range issuers {
+		m.issuers[iss] = true
+	}
+	return m
+}
+
+func (m *Metrics) success(issuer string) {
+	m.mu.Lock()
+	m.successes[issuer]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) failure(kind ErrorKind, issuer string) {
+	if !m.issuers[issuer] {
+		issuer = UnknownIssuer
+	}
+	m.mu.Lock()
+	m.failures[failureKey{kind: kind, issuer: issuer}]++
+	m.mu.Unlock()
+}
+
+// Successes returns the number of successful authentications for the issuer.
+func (m *Metrics) Successes(issuer string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.successes[issuer]
+}
+
+// Failures returns the number of failed authentications of the given kind, for all issuers.
+func (m *Metrics) Failures(kind ErrorKind) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n uint64
+	for k, v := range m.failures {
+		if k.kind == kind {
+			n += v
+		}
+	}
+	return n
+}
+
+// WritePrometheus writes the counters in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	var successes, failures []string
+	for issuer, n := range m.successes {
+		successes = append(successes, fmt.Sprintf("jwt_auth_success_total{issuer=\"%s\"} %d\n",
+			escapeLabel(issuer), n))
+	}
+	for k, n := range m.failures {
+		failures = append(failures, fmt.Sprintf("jwt_auth_failure_total{kind=\"%s\",issuer=\"%s\"} %d\n",
+			k.kind, escapeLabel(k.issuer), n))
+	}
+	m.mu.Unlock()
+	sort.Strings(successes)
+	sort.Strings(failures)
+
+	var b strings.Builder
+	b.WriteString("# HELP jwt_auth_success_total Successful token authentications.\n")
+	b.WriteString("# TYPE jwt_auth_success_total counter\n")
+	b.WriteString(strings.Join(successes, ""))
+	b.WriteString("# HELP jwt_auth_failure_total Failed token authentications by error kind.\n")
+	b.WriteString("# TYPE jwt_auth_failure_total counter\n")
+	b.WriteString(strings.Join(failures, ""))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Serve is an Iris handler exposing the counters for Prometheus to scrape.
+func (m *Metrics) Serve(ctx iris.Context) {
+	ctx.ContentType(prometheusContentType)
+	_ = m.WritePrometheus(ctx.ResponseWriter())
+}
+
+// ServeHTTP exposes the counters on a net/http server.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", prometheusContentType)
+	_ = m.WritePrometheus(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// routePattern is a compiled entry of `Config.Include` or `Config.Exclude`.
+type routePattern struct {
+	methods  []string
+	segments []string
+}
+
+// compileRoutes parses entries of the form "[METHOD[,METHOD...] ]/path/pattern".
+func compileRoutes(specs []string) []routePattern {
+	routes := make([]routePattern, 0, len(specs))
+	for _, spec := range specs {
+		var r routePattern
+		spec = strings.TrimSpace(spec)
+		if i := strings.IndexByte(spec, ' '); i > 0 {
+			for _, method := range strings.Split(spec[:i], ",") {
+				r.methods = append(r.methods, strings.ToUpper(strings.TrimSpace(method)))
+			}
+			spec = strings.TrimSpace(spec[i+1:])
+		}
+		r.segments = strings.Split(strings.Trim(spec, "/"), "/")
+		routes = append(routes, r)
+	}
+	return routes
+}
+
+func (r routePattern) match(method, p string) bool {
+	if len(r.methods) > 0 && !containsString(r.methods, method) {
+		return false
+	}
+	return matchSegments(r.segments, strings.Split(strings.Trim(p, "/"), "/"))
+}
+
+// matchSegments matches each segment with path.Match, "**" matches
+// any number of segments, including none.
+func matchSegments(pattern, segments []string) bool {
+	for i, seg := range pattern {
+		if seg == "**" {
+			rest := pattern[i+1:]
+			for j := i; j <= len(segments); j++ {
+				if matchSegments(rest, segments[j:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if ok, err := path.Match(seg, segments[i]); !ok || err != nil {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
+
+func matchAny(routes []routePattern, method, p string) bool {
+	for _, r := range routes {
+		if r.match(method, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipRoute reports whether the include/exclude lists let the request bypass authentication.
+// Paths that are not clean, e.g. "/public/../admin" or "//public", are never skipped
+// since routers may resolve them to another route than the one they seem to match.
+func (m *Middleware) skipRoute(r *http.Request) bool {
+	if len(m.exclude) == 0 && len(m.include) == 0 {
+		return false
+	}
+	p := r.URL.Path
+	if cleaned := path.Clean("/" + p); cleaned != p && cleaned+"/" != p {
+		return false
+	}
+	if matchAny(m.exclude, r.Method, p) {
+		return true
+	}
+	return len(m.include) > 0 && !matchAny(m.include, r.Method, p)
+}
@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSkipRoute(t *testing.T) {
+	m := New(Config{
+		Exclude: []string{"GET /health", "/login", "GET,POST /captcha/*", "/public/**"},
+	})
+	tests := []struct {
+		method, path string
+		skip         bool
+	}{
+		{"GET", "/health", true},
+		{"GET", "/health/", true},
+		{"POST", "/health", false},
+		{"POST", "/login", true},
+		{"GET", "/captcha/123", true},
+		{"DELETE", "/captcha/123", false},
+		{"GET", "/captcha/123/x", false},
+		{"GET", "/public", true},
+		{"GET", "/public/css/site.css", true},
+		{"GET", "/admin", false},
+		{"GET", "/public/../admin", false},
+		{"GET", "/public/./x", false},
+		{"GET", "//public/x", false},
+		{"GET", "/public//x", false},
+		{"GET", "/login/..", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.URL.Path = tt.path
+		if skip := m.skipRoute(r); skip != tt.skip {
+			t.Errorf("%s %s: expected skip %v but got %v", tt.method, tt.path, tt.skip, skip)
+		}
+	}
+}
+
+func TestSkipRouteInclude(t *testing.T) {
+	m := New(Config{Include: []string{"/api/**"}, Exclude: []string{"/api/public/*"}})
+	tests := []struct {
+		path string
+		skip bool
+	}{
+		{"/api/users", false},
+		{"/api/public/x", true},
+		{"/static/app.js", true},
+		{"/static/../api/users", false},
+		{"/api/public/../users", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.URL.Path = tt.path
+		if skip := m.skipRoute(r); skip != tt.skip {
+			t.Errorf("%s: expected skip %v but got %v", tt.path, tt.skip, skip)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	// ErrTenantUnknown is the error value that it's returned when
+	// no tenant of `Config.Tenants` matches the request.
+	ErrTenantUnknown = errors.New("unknown tenant")
+
+	// ErrTokenIssuer is the error value that it's returned when
+	// the "iss" claim does not match the tenant issuer.
+	ErrTokenIssuer = errors.New("token issuer is invalid")
+
+	// ErrTokenAudience is the error value that it's returned when
+	// the "aud" claim does not contain the tenant audience.
+	ErrTokenAudience = errors.New("token audience is invalid")
+)
+
+// Tenant holds the key set and validation policy of one tenant
+// served by a multi-tenant middleware, see `Config.Tenants`.
+type Tenant struct {
+	// ID is the value the TenantResolver returns for this tenant.
+	ID string
+	// Issuer, when set, must equal the "iss" claim.
+	Issuer string
+	// Audience, when set, must be contained in the "aud" claim.
+	Audience string
+	// ValidationKeyGetter returns the key to validate the tenant tokens,
+	// when nil the key is looked up in Keys by the "kid" header.
+	ValidationKeyGetter jwt.Keyfunc
+	// Keys of the tenant by key id, a single key may be stored under "".
+	Keys map[string]interface{}
+	// SigningMethod, when set, is the only algorithm accepted for the tenant.
+	SigningMethod jwt.SigningMethod
+	// Expiration enables the check of the "exp" claim for the tenant.
+	Expiration bool
+}
+
+func (t *Tenant) keyGetter() jwt.Keyfunc {
+	if t.ValidationKeyGetter != nil {
+		return t.ValidationKeyGetter
+	}
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := t.Keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("no key %q for tenant %s", kid, t.ID)
+	}
+}
+
+// TenantRegistry is the set of tenants a single middleware instance serves.
+// It's safe for concurrent use, tenants may be registered while serving.
+type TenantRegistry struct {
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// NewTenantRegistry returns a registry holding the given tenants.
+func NewTenantRegistry(tenants ...*Tenant) *TenantRegistry {
+	r := &TenantRegistry{tenants: make(map[string]*Tenant)}
+	for _, t := range tenants {
+		r.Register(t)
+	}
+	return r
+}
+
+// Register adds the tenant, replacing any tenant with the same ID.
+func (r *TenantRegistry) Register(t *Tenant) {
+	r.mu.Lock()
+	r.tenants[t.ID] = t
+	r.mu.Unlock()
+}
+
+// Remove deletes the tenant with the given ID.
+func (r *TenantRegistry) Remove(id string) {
+	r.mu.Lock()
+	delete(r.tenants, id)
+	r.mu.Unlock()
+}
+
+// Lookup returns the tenant with the given ID.
+func (r *TenantRegistry) Lookup(id string) (*Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[id]
+	return t, ok
+}
+
+// lookupIssuer returns the tenant whose Issuer is iss.
+func (r *TenantRegistry) lookupIssuer(iss string) (*Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.tenants {
+		if t.Issuer != "" && t.Issuer == iss {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// TenantResolver returns the ID of the tenant a request belongs to. The
+// claims are decoded from the token but NOT verified yet, only use them to
+// pick the tenant. An empty ID means the resolver could not tell.
+type TenantResolver func(r *http.Request, unverified jwt.MapClaims) (string, error)
+
+// TenantByHost resolves the tenant ID from the request host, without port.
+func TenantByHost() TenantResolver {
+	return func(r *http.Request, _ jwt.MapClaims) (string, error) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return strings.ToLower(host), nil
+	}
+}
+
+// TenantByHeader resolves the tenant ID from the specified header.
+func TenantByHeader(name string) TenantResolver {
+	return func(r *http.Request, _ jwt.MapClaims) (string, error) {
+		return r.Header.Get(name), nil
+	}
+}
+
+// TenantByPathPrefix resolves the tenant ID from the first segment of the path,
+// e.g. "acme" for "/acme/api/users".
+func TenantByPathPrefix() TenantResolver {
+	return func(r *http.Request, _ jwt.MapClaims) (string, error) {
+		p := strings.TrimPrefix(r.URL.Path, "/")
+		if i := strings.IndexByte(p, '/'); i >= 0 {
+			p = p[:i]
+		}
+		return p, nil
+	}
+}
+
+// TenantByIssuer resolves the tenant whose Issuer equals the unverified "iss" claim.
+// The issuer is verified against the tenant afterwards like for any other resolver.
+func TenantByIssuer(registry *TenantRegistry) TenantResolver {
+	return func(r *http.Request, unverified jwt.MapClaims) (string, error) {
+		iss, _ := unverified["iss"].(string)
+		if t, ok := registry.lookupIssuer(iss); ok {
+			return t.ID, nil
+		}
+		return "", nil
+	}
+}
+
+// TenantByFirst returns a resolver that runs multiple resolvers and takes
+// the first tenant ID it finds
+func TenantByFirst(resolvers ...TenantResolver) TenantResolver {
+	return func(r *http.Request, unverified jwt.MapClaims) (string, error) {
+		for _, resolve := range resolvers {
+			id, err := resolve(r, unverified)
+			if err != nil {
+				return "", err
+			}
+			if id != "" {
+				return id, nil
+			}
+		}
+		return "", nil
+	}
+}
+
+// resolveTenant picks the tenant of the request, a nil tenant and no error
+// means the middleware is not multi-tenant.
+func (m *Middleware) resolveTenant(r *http.Request, token string) (*Tenant, error) {
+	if m.Config.Tenants == nil {
+		return nil, nil
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwtParser.ParseUnverified(token, claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	resolve := m.Config.TenantResolver
+	if resolve == nil {
+		resolve = TenantByIssuer(m.Config.Tenants)
+	}
+	id, err := resolve(r, claims)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := m.Config.Tenants.Lookup(id)
+	if !ok {
+		return nil, ErrTenantUnknown
+	}
+	return t, nil
+}
+
+// checkTenantClaims verifies the issuer and audience policy of the tenant.
+func checkTenantClaims(t *Tenant, claims jwt.Claims) error {
+	mc, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ErrTokenInvalid
+	}
+	if t.Issuer != "" && !mc.VerifyIssuer(t.Issuer, true) {
+		return ErrTokenIssuer
+	}
+	if t.Audience != "" && !mc.VerifyAudience(t.Audience, true) {
+		return ErrTokenAudience
+	}
+	return nil
+}
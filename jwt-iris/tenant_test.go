@@ -0,0 +1,183 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+)
+
+var (
+	acmeKey   = []byte("acme secret")
+	globexKey = []byte("globex secret")
+)
+
+// tenants returns "acme", checking exp, iss and aud of HS256 tokens, and
+// "globex", accepting HS512 tokens signed with the "k1" key without exp.
+func tenants() *TenantRegistry {
+	return NewTenantRegistry(
+		&Tenant{ID: "acme", Issuer: "https://acme", Audience: "api", Keys: map[string]interface{}{"": acmeKey},
+			SigningMethod: SigningMethodHS256, Expiration: true},
+		&Tenant{ID: "globex", Issuer: "https://globex", Keys: map[string]interface{}{"k1": globexKey},
+			SigningMethod: SigningMethodHS512},
+	)
+}
+
+func signTenantToken(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key []byte) string {
+	t.Helper()
+	token := NewTokenWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestTenants(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	acme := signTenantToken(t, SigningMethodHS256, "", jwt.MapClaims{"sub": "alice", "iss": "https://acme", "aud": "api", "exp": exp}, acmeKey)
+	globex := signTenantToken(t, SigningMethodHS512, "k1", jwt.MapClaims{"sub": "bob", "iss": "https://globex"}, globexKey)
+	// Signed with the acme key but claiming to come from globex.
+	crossKey := signTenantToken(t, SigningMethodHS512, "k1", jwt.MapClaims{"sub": "mallory", "iss": "https://globex"}, acmeKey)
+	// Signed with the globex key but claiming to come from acme.
+	crossIssuer := signTenantToken(t, SigningMethodHS512, "k1", jwt.MapClaims{"sub": "mallory", "iss": "https://acme"}, globexKey)
+	wrongAudience := signTenantToken(t, SigningMethodHS256, "", jwt.MapClaims{"sub": "alice", "iss": "https://acme", "aud": "other", "exp": exp}, acmeKey)
+	noExp := signTenantToken(t, SigningMethodHS256, "", jwt.MapClaims{"sub": "alice", "iss": "https://acme", "aud": "api"}, acmeKey)
+	expired := signTenantToken(t, SigningMethodHS256, "", jwt.MapClaims{"sub": "alice", "iss": "https://acme", "aud": "api",
+		"exp": time.Now().Add(-time.Hour).Unix()}, acmeKey)
+	acmeHS512 := signTenantToken(t, SigningMethodHS512, "", jwt.MapClaims{"sub": "alice", "iss": "https://acme", "aud": "api", "exp": exp}, acmeKey)
+	unknown := signTenantToken(t, SigningMethodHS256, "", jwt.MapClaims{"sub": "alice", "iss": "https://initech"}, acmeKey)
+
+	byHeader := func(c *Config) { c.TenantResolver = TenantByHeader("X-Tenant") }
+	tests := []struct {
+		name   string
+		config func(*Config)
+		url    string
+		tenant string
+		token  string
+		status int
+		body   string
+	}{
+		{name: "issuer", token: acme, status: http.StatusOK, body: "alice"},
+		{name: "issuer other tenant", token: globex, status: http.StatusOK, body: "bob"},
+		{name: "key of another tenant", token: crossKey, status: http.StatusUnauthorized},
+		{name: "unknown issuer", token: unknown, status: http.StatusUnauthorized, body: ErrTenantUnknown.Error()},
+		{name: "audience", token: wrongAudience, status: http.StatusUnauthorized, body: ErrTokenAudience.Error()},
+		{name: "tenant expiration", token: expired, status: http.StatusUnauthorized},
+		{name: "tenant expiration requires exp", token: noExp, status: http.StatusUnauthorized, body: ErrTokenExpired.Error()},
+		{name: "tenant signing method", token: acmeHS512, status: http.StatusUnauthorized},
+
+		{name: "header", config: byHeader, tenant: "acme", token: acme, status: http.StatusOK, body: "alice"},
+		{name: "header sent to another tenant", config: byHeader, tenant: "globex", token: acme, status: http.StatusUnauthorized},
+		{name: "header issuer", config: byHeader, tenant: "globex", token: crossIssuer, status: http.StatusUnauthorized, body: ErrTokenIssuer.Error()},
+		{name: "header unknown", config: byHeader, tenant: "initech", token: acme, status: http.StatusUnauthorized, body: ErrTenantUnknown.Error()},
+		{name: "header missing", config: byHeader, token: acme, status: http.StatusUnauthorized, body: ErrTenantUnknown.Error()},
+
+		{name: "host", config: func(c *Config) { c.TenantResolver = TenantByHost() }, url: "http://ACME:8080/", token: acme, status: http.StatusOK, body: "alice"},
+		{name: "host sent to another tenant", config: func(c *Config) { c.TenantResolver = TenantByHost() }, url: "http://globex/", token: acme, status: http.StatusUnauthorized},
+
+		{name: "path prefix", config: func(c *Config) { c.TenantResolver = TenantByPathPrefix() }, url: "/globex/users", token: globex, status: http.StatusOK, body: "bob"},
+		{name: "path prefix sent to another tenant", config: func(c *Config) { c.TenantResolver = TenantByPathPrefix() }, url: "/acme/users", token: globex, status: http.StatusUnauthorized},
+
+		{name: "first header", config: func(c *Config) { c.TenantResolver = TenantByFirst(TenantByHeader("X-Tenant"), TenantByPathPrefix()) },
+			url: "/acme/users", tenant: "globex", token: globex, status: http.StatusOK, body: "bob"},
+		{name: "first path prefix", config: func(c *Config) { c.TenantResolver = TenantByFirst(TenantByHeader("X-Tenant"), TenantByPathPrefix()) },
+			url: "/acme/users", token: acme, status: http.StatusOK, body: "alice"},
+		{name: "first none", config: func(c *Config) { c.TenantResolver = TenantByFirst(TenantByHeader("X-Tenant")) },
+			token: acme, status: http.StatusUnauthorized, body: ErrTenantUnknown.Error()},
+	}
+	for _, a := range adapters {
+		for _, tt := range tests {
+			t.Run(a.name+"/"+tt.name, func(t *testing.T) {
+				// The global key and policy must not be used for tenants.
+				c := testConfig()
+				c.Tenants = tenants()
+				if tt.config != nil {
+					tt.config(&c)
+				}
+				url := tt.url
+				if url == "" {
+					url = "/"
+				}
+				r := httptest.NewRequest("GET", url, nil)
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+				if tt.tenant != "" {
+					r.Header.Set("X-Tenant", tt.tenant)
+				}
+				w := httptest.NewRecorder()
+				a.serve(New(c), w, r)
+				if w.Code != tt.status {
+					t.Fatalf("expected status %d but got %d: %s", tt.status, w.Code, w.Body)
+				}
+				if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+					t.Fatalf("expected body %q but got %q", tt.body, w.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestGetTenant(t *testing.T) {
+	globex := signTenantToken(t, SigningMethodHS512, "k1", jwt.MapClaims{"sub": "bob", "iss": "https://globex"}, globexKey)
+	m := New(Config{Tenants: tenants()})
+
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.Get("/", func(ctx iris.Context) { m.Serve(*ctx) }, func(ctx iris.Context) {
+		tenant := m.GetTenant(*ctx)
+		if tenant == nil {
+			ctx.WriteString("none")
+			return
+		}
+		ctx.WriteString(tenant.ID + " " + m.Get(*ctx).Claims.(jwt.MapClaims)["sub"].(string))
+	})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+globex)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	if body := w.Body.String(); body != "globex bob" {
+		t.Fatalf("expected the globex tenant but got %d %q", w.Code, body)
+	}
+
+	var tenant *Tenant
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ = TenantFromContext(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if tenant == nil || tenant.ID != "globex" {
+		t.Fatalf("expected the globex tenant but got %v", tenant)
+	}
+
+	if New(testConfig()).GetTenant(*context.NewContext(app)) != nil {
+		t.Fatal("expected no tenant without Config.Tenants")
+	}
+	if _, ok := TenantFromContext(httptest.NewRequest("GET", "/", nil).Context()); ok {
+		t.Fatal("expected no tenant in a plain request context")
+	}
+}
+
+func TestTenantRegistry(t *testing.T) {
+	r := tenants()
+	if _, ok := r.Lookup("acme"); !ok {
+		t.Fatal("expected the acme tenant")
+	}
+	r.Register(&Tenant{ID: "acme", Issuer: "https://acme.example"})
+	if tenant, _ := r.Lookup("acme"); tenant.Issuer != "https://acme.example" {
+		t.Fatalf("expected the tenant to be replaced but got %v", tenant)
+	}
+	r.Remove("acme")
+	if _, ok := r.Lookup("acme"); ok {
+		t.Fatal("expected the acme tenant to be removed")
+	}
+}
@@ -0,0 +1,220 @@
+// Package password hashes and verifies user passwords and checks them against a policy.
+//
+// Hashes are encoded in the PHC string format, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//	$2a$10$<bcrypt salt and hash>
+//
+// Unsalted MD5 hex digests produced by tools.Tool.EncodeMD5 are still verified,
+// always reporting that they need to be rehashed, so legacy accounts can be
+// migrated transparently on their next login.
+package password
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm identifies a password hashing algorithm.
+type Algorithm string
+
+// The supported algorithms, MD5 can only be verified.
+const (
+	Argon2id Algorithm = "argon2id"
+	Bcrypt   Algorithm = "bcrypt"
+	MD5      Algorithm = "md5"
+)
+
+var (
+	// ErrUnknownHash is returned when the encoded hash format is not recognized.
+	ErrUnknownHash = errors.New("password: unknown hash format")
+	// ErrMalformedHash is returned when the encoded hash can not be decoded.
+	ErrMalformedHash = errors.New("password: malformed hash")
+)
+
+// Argon2idParams are the cost parameters of argon2id.
+type Argon2idParams struct {
+	// Memory in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follow the recommendations of RFC 9106 for memory constrained servers.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Config is a struct for specifying configuration options of the Hasher.
+type Config struct {
+	// Algorithm used for new hashes.
+	// Default: Argon2id
+	Algorithm Algorithm
+	// Argon2id cost parameters, each zero field gets its value of DefaultArgon2idParams.
+	// Default: DefaultArgon2idParams
+	Argon2id Argon2idParams
+	// BcryptCost is the bcrypt cost factor.
+	// Default: bcrypt.DefaultCost
+	BcryptCost int
+}
+
+// Hasher hashes passwords with the configured algorithm and verifies
+// hashes of any supported algorithm.
+type Hasher struct {
+	Config Config
+}
+
+// NewHasher constructs a new Hasher with supplied options.
+func NewHasher(cfg ...Config) *Hasher {
+	var c Config
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = Argon2id
+	}
+	if c.Argon2id.Memory == 0 {
+		c.Argon2id.Memory = DefaultArgon2idParams.Memory
+	}
+	if c.Argon2id.Iterations == 0 {
+		c.Argon2id.Iterations = DefaultArgon2idParams.Iterations
+	}
+	if c.Argon2id.Parallelism == 0 {
+		c.Argon2id.Parallelism = DefaultArgon2idParams.Parallelism
+	}
+	if c.Argon2id.SaltLength == 0 {
+		c.Argon2id.SaltLength = DefaultArgon2idParams.SaltLength
+	}
+	if c.Argon2id.KeyLength == 0 {
+		c.Argon2id.KeyLength = DefaultArgon2idParams.KeyLength
+	}
+	if c.BcryptCost == 0 {
+		c.BcryptCost = bcrypt.DefaultCost
+	}
+	return &Hasher{Config: c}
+}
+
+// Hash returns the encoded hash of the password.
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.Config.Algorithm {
+	case Argon2id:
+		p := h.Config.Argon2id
+		salt := make([]byte, p.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return encodeArgon2id(p, salt, key), nil
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Config.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+	return "", fmt.Errorf("password: can not hash with %q", h.Config.Algorithm)
+}
+
+// Verify reports whether password matches the encoded hash, and whether the
+// hash should be replaced by a new one from Hash because it was produced by
+// another algorithm or with other parameters than the configured ones.
+// needsRehash is only meaningful when ok is true.
+func (h *Hasher) Verify(encoded, password string) (ok, needsRehash bool, err error) {
+	switch Identify(encoded) {
+	case Argon2id:
+		p, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		ok = subtle.ConstantTimeCompare(key, other) == 1
+		p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+		return ok, h.Config.Algorithm != Argon2id || p != h.Config.Argon2id, nil
+	case Bcrypt:
+		err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, ErrMalformedHash
+		}
+		cost, _ := bcrypt.Cost([]byte(encoded))
+		return true, h.Config.Algorithm != Bcrypt || cost != h.Config.BcryptCost, nil
+	case MD5:
+		sum := md5.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(encoded))) == 1, true, nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+var md5Hex = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// Identify returns the algorithm of an encoded hash, "" if it's not recognized.
+func Identify(encoded string) Algorithm {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return Argon2id
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return Bcrypt
+	case md5Hex.MatchString(encoded):
+		return MD5
+	}
+	return ""
+}
+
+var b64 = base64.RawStdEncoding
+
+// maxArgon2idMemory and maxArgon2idIterations bound the memory, in KiB, and
+// the passes of the argon2id hashes that are verified, so a hostile stored
+// hash can not exhaust the memory or keep Verify busy.
+const (
+	maxArgon2idMemory     = 1 << 20
+	maxArgon2idIterations = 64
+)
+
+func encodeArgon2id(p Argon2idParams, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+func decodeArgon2id(encoded string) (p Argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrMalformedHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrMalformedHash
+	}
+	// Sscanf ignores trailing input, the parameters must be exactly the ones encodeArgon2id writes.
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil ||
+		parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism) {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if p.Iterations < 1 || p.Iterations > maxArgon2idIterations || p.Parallelism < 1 || p.Memory > maxArgon2idMemory {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if salt, err = b64.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if key, err = b64.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+	return p, salt, key, nil
+}
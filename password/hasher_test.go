@@ -0,0 +1,155 @@
+package password
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters, the hashes are only verified by the tests
+var testArgon2id = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}
+
+func TestHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		config Config
+		format *regexp.Regexp
+	}{
+		{Config{Argon2id: testArgon2id}, regexp.MustCompile(`^\$argon2id\$v=19\$m=1024,t=1,p=1\$[A-Za-z0-9+/]{11}\$[A-Za-z0-9+/]{22}$`)},
+		{Config{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}, regexp.MustCompile(`^\$2a\$04\$[./A-Za-z0-9]{53}$`)},
+	}
+	for _, tt := range tests {
+		h := NewHasher(tt.config)
+		encoded, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tt.format.MatchString(encoded) {
+			t.Fatalf("%s: unexpected hash %q", h.Config.Algorithm, encoded)
+		}
+		if Identify(encoded) != h.Config.Algorithm {
+			t.Fatalf("%s: identified as %q", h.Config.Algorithm, Identify(encoded))
+		}
+		ok, needsRehash, err := h.Verify(encoded, "correct horse")
+		if !ok || needsRehash || err != nil {
+			t.Fatalf("%s: expected a match without rehash but got %v, %v, %v", h.Config.Algorithm, ok, needsRehash, err)
+		}
+		if ok, _, err = h.Verify(encoded, "wrong horse"); ok || err != nil {
+			t.Fatalf("%s: expected a mismatch but got %v, %v", h.Config.Algorithm, ok, err)
+		}
+		// Two hashes of the same password differ by their salt.
+		if other, _ := h.Hash("correct horse"); other == encoded {
+			t.Fatalf("%s: expected a random salt", h.Config.Algorithm)
+		}
+	}
+
+	if _, err := NewHasher(Config{Algorithm: MD5}).Hash("x"); err == nil {
+		t.Fatal("expected MD5 hashing to be refused")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon, err := NewHasher(Config{Argon2id: testArgon2id}).Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := NewHasher(Config{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}).Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(f func(p *Argon2idParams)) Config {
+		p := testArgon2id
+		f(&p)
+		return Config{Argon2id: p}
+	}
+	tests := []struct {
+		name    string
+		config  Config
+		encoded string
+		rehash  bool
+	}{
+		{"same argon2id parameters", Config{Argon2id: testArgon2id}, argon, false},
+		{"argon2id memory", with(func(p *Argon2idParams) { p.Memory = 2048 }), argon, true},
+		{"argon2id iterations", with(func(p *Argon2idParams) { p.Iterations = 2 }), argon, true},
+		{"argon2id parallelism", with(func(p *Argon2idParams) { p.Parallelism = 2 }), argon, true},
+		{"argon2id salt length", with(func(p *Argon2idParams) { p.SaltLength = 16 }), argon, true},
+		{"argon2id key length", with(func(p *Argon2idParams) { p.KeyLength = 32 }), argon, true},
+		{"argon2id to bcrypt", Config{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}, argon, true},
+		{"same bcrypt cost", Config{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}, bcryptHash, false},
+		{"bcrypt cost", Config{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost + 1}, bcryptHash, true},
+		{"bcrypt to argon2id", Config{Argon2id: testArgon2id}, bcryptHash, true},
+		{"md5", Config{Argon2id: testArgon2id}, "5ebe2294ecd0e0f08eab7690d2a6ee69", true},
+	}
+	for _, tt := range tests {
+		ok, needsRehash, err := NewHasher(tt.config).Verify(tt.encoded, "secret")
+		if !ok || err != nil {
+			t.Fatalf("%s: expected a match but got %v, %v", tt.name, ok, err)
+		}
+		if needsRehash != tt.rehash {
+			t.Errorf("%s: expected needsRehash %v but got %v", tt.name, tt.rehash, needsRehash)
+		}
+	}
+}
+
+func TestVerifyLegacyMD5(t *testing.T) {
+	h := NewHasher()
+	// md5("secret")
+	for _, encoded := range []string{"5ebe2294ecd0e0f08eab7690d2a6ee69", "5EBE2294ECD0E0F08EAB7690D2A6EE69"} {
+		if Identify(encoded) != MD5 {
+			t.Fatalf("%s: identified as %q", encoded, Identify(encoded))
+		}
+		ok, needsRehash, err := h.Verify(encoded, "secret")
+		if !ok || !needsRehash || err != nil {
+			t.Fatalf("%s: expected a match to rehash but got %v, %v, %v", encoded, ok, needsRehash, err)
+		}
+		if ok, _, err = h.Verify(encoded, "Secret"); ok || err != nil {
+			t.Fatalf("%s: expected a mismatch but got %v, %v", encoded, ok, err)
+		}
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	const salt, key = "c2FsdHNhbHQ", "a2V5a2V5a2V5a2V5a2V5aw"
+	tests := []struct {
+		encoded string
+		err     error
+	}{
+		{"", ErrUnknownHash},
+		{"secret", ErrUnknownHash},
+		{"$scrypt$ln=16,r=8,p=1$c2FsdA$aGFzaA", ErrUnknownHash},
+		{"5ebe2294ecd0e0f08eab7690d2a6ee6", ErrUnknownHash},
+		{"5ebe2294ecd0e0f08eab7690d2a6ee6z", ErrUnknownHash},
+		{"$argon2id$v=19$m=1024,t=1,p=1$" + salt, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$" + key + "$extra", ErrMalformedHash},
+		{"$argon2id$v=16$m=1024,t=1,p=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$19$m=1024,t=1,p=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,p=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=1,p=1$!!$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$", ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$!!", ErrMalformedHash},
+		// Oversized parameters are rejected before any work is done.
+		{"$argon2id$v=19$m=4294967295,t=1,p=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=4294967295,p=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=65,p=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=1,p=256$" + salt + "$" + key, ErrMalformedHash},
+		{"$argon2id$v=19$m=1024,t=1,p=1,x=1$" + salt + "$" + key, ErrMalformedHash},
+		{"$2a$04$short", ErrMalformedHash},
+		{"$2a$99$" + strings.Repeat("a", 53), ErrMalformedHash},
+	}
+	h := NewHasher()
+	for _, tt := range tests {
+		ok, needsRehash, err := h.Verify(tt.encoded, "secret")
+		if ok || needsRehash || err != tt.err {
+			t.Errorf("%q: expected %v but got %v, %v, %v", tt.encoded, tt.err, ok, needsRehash, err)
+		}
+	}
+
+	// The bounds themselves are accepted.
+	encoded := "$argon2id$v=19$m=8,t=64,p=1$" + salt + "$" + key
+	if _, _, err := h.Verify(encoded, "secret"); err != nil {
+		t.Fatalf("%q: %v", encoded, err)
+	}
+}
@@ -0,0 +1,381 @@
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// The codes of the policy violations, they are the keys of the message templates.
+const (
+	CodeMinLength = "min_length"
+	CodeMaxLength = "max_length"
+	CodeDigit     = "digit"
+	CodeLetter    = "letter"
+	CodeLower     = "lower"
+	CodeUpper     = "upper"
+	CodeSymbol    = "symbol"
+	CodeRepeat    = "repeat"
+	CodeBanned    = "banned"
+	CodeSimilar   = "similar"
+	CodeEntropy   = "entropy"
+)
+
+// DefaultLocale is the locale of Violation.Error and Violations.Error.
+const DefaultLocale = "en"
+
+var (
+	messagesMu sync.RWMutex
+	messages   = map[string]map[string]string{
+		"en": {
+			CodeMinLength: "password must be at least {min} characters long",
+			CodeMaxLength: "password must be at most {max} characters long",
+			CodeDigit:     "password must contain a digit",
+			CodeLetter:    "password must contain a letter",
+			CodeLower:     "password must contain a lowercase letter",
+			CodeUpper:     "password must contain an uppercase letter",
+			CodeSymbol:    "password must contain a symbol",
+			CodeRepeat:    "password must not repeat a character more than {max} times in a row",
+			CodeBanned:    "password is too common",
+			CodeSimilar:   "password must not contain the username or email",
+			CodeEntropy:   "password is too easy to guess",
+		},
+		"zh": {
+			CodeMinLength: "密码长度不能少于{min}个字符",
+			CodeMaxLength: "密码长度不能超过{max}个字符",
+			CodeDigit:     "密码必须包含数字",
+			CodeLetter:    "密码必须包含字母",
+			CodeLower:     "密码必须包含小写字母",
+			CodeUpper:     "密码必须包含大写字母",
+			CodeSymbol:    "密码必须包含特殊字符",
+			CodeRepeat:    "密码中同一字符不能连续出现超过{max}次",
+			CodeBanned:    "密码过于常见",
+			CodeSimilar:   "密码不能包含用户名或邮箱",
+			CodeEntropy:   "密码强度太低",
+		},
+		"zh_Hant": {
+			CodeMinLength: "密碼長度不能少於{min}個字元",
+			CodeMaxLength: "密碼長度不能超過{max}個字元",
+			CodeDigit:     "密碼必須包含數字",
+			CodeLetter:    "密碼必須包含字母",
+			CodeLower:     "密碼必須包含小寫字母",
+			CodeUpper:     "密碼必須包含大寫字母",
+			CodeSymbol:    "密碼必須包含特殊字元",
+			CodeRepeat:    "密碼中同一字元不能連續出現超過{max}次",
+			CodeBanned:    "密碼過於常見",
+			CodeSimilar:   "密碼不能包含使用者名稱或電子郵件",
+			CodeEntropy:   "密碼強度太低",
+		},
+	}
+)
+
+// RegisterMessages adds or overrides the message templates of a locale.
+// Templates reference the violation parameters as {name}.
+func RegisterMessages(locale string, templates map[string]string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	m, ok := messages[locale]
+	if !ok {
+		m = make(map[string]string)
+		messages[locale] = m
+	}
+	for code, tpl := range templates {
+		m[code] = tpl
+	}
+}
+
+// Violation is a rule of the policy the password breaks.
+type Violation struct {
+	Code   string
+	Params map[string]interface{}
+}
+
+// Translate returns the message of the violation in the locale,
+// falling back to DefaultLocale and then to the code.
+func (v Violation) Translate(locale string) string {
+	messagesMu.RLock()
+	tpl, ok := messages[locale][v.Code]
+	if !ok {
+		tpl, ok = messages[DefaultLocale][v.Code]
+	}
+	messagesMu.RUnlock()
+	if !ok {
+		return v.Code
+	}
+	for k, p := range v.Params {
+		tpl = strings.Replace(tpl, "{"+k+"}", fmt.Sprint(p), -1)
+	}
+	return tpl
+}
+
+// Error implements error.
+func (v Violation) Error() string {
+	return v.Translate(DefaultLocale)
+}
+
+// Violations are all the rules a password breaks.
+type Violations []Violation
+
+// Translate returns the messages of the violations in the locale.
+func (vs Violations) Translate(locale string) []string {
+	list := make([]string, 0, len(vs))
+	for _, v := range vs {
+		list = append(list, v.Translate(locale))
+	}
+	return list
+}
+
+// Error implements error.
+func (vs Violations) Error() string {
+	return strings.Join(vs.Translate(DefaultLocale), ", ")
+}
+
+// Has reports whether one of the violations has the code.
+func (vs Violations) Has(code string) bool {
+	for _, v := range vs {
+		if v.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy is a configurable set of password rules, the zero value accepts any password.
+type Policy struct {
+	// MinLength and MaxLength bound the number of characters, 0 disables the bound.
+	MinLength, MaxLength int
+	// Character classes the password must contain, RequireLetter accepts either case.
+	RequireDigit, RequireLetter, RequireLower, RequireUpper, RequireSymbol bool
+	// MaxRepeat is the maximum number of times a character may repeat in a row, 0 disables the rule.
+	MaxRepeat int
+	// MinEntropy is the minimum estimated entropy in bits, see Entropy. 0 disables the rule.
+	MinEntropy float64
+	// CheckSimilarity rejects passwords containing one of the identities passed
+	// to Check (username, email or its local part), ignoring case.
+	CheckSimilarity bool
+	// ASCII counts the length in bytes and only ASCII digits and letters,
+	// any other character is a symbol, like tools.Tool.CheckPasswordLevel always did.
+	// Otherwise the length is in characters and Unicode digits and letters count,
+	// uncased letters, e.g. CJK, satisfy RequireLetter but neither RequireLower nor RequireUpper.
+	ASCII bool
+
+	mu     sync.RWMutex
+	banned map[string]struct{}
+}
+
+// DefaultPolicy is the policy of tools.Tool.CheckPasswordLevel:
+// at least 8 bytes with an ASCII digit and an ASCII letter.
+var DefaultPolicy = &Policy{MinLength: 8, RequireDigit: true, RequireLetter: true, ASCII: true}
+
+// Ban adds passwords to the banned list, the comparison ignores case.
+func (p *Policy) Ban(passwords ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.banned == nil {
+		p.banned = make(map[string]struct{})
+	}
+	for _, pw := range passwords {
+		if pw = strings.TrimSpace(pw); pw != "" {
+			p.banned[strings.ToLower(pw)] = struct{}{}
+		}
+	}
+}
+
+// LoadBanned adds the passwords of a local file to the banned list,
+// one per line, blank lines and lines starting with "#" are ignored.
+func (p *Policy) LoadBanned(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var list []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			list = append(list, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	p.Ban(list...)
+	return nil
+}
+
+func (p *Policy) isBanned(password string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.banned[strings.ToLower(password)]
+	return ok
+}
+
+// Check returns all the rules the password breaks, nil when it complies.
+// identities are the username, email... used by CheckSimilarity.
+func (p *Policy) Check(password string, identities ...string) Violations {
+	var vs Violations
+	add := func(code string, params map[string]interface{}) {
+		vs = append(vs, Violation{Code: code, Params: params})
+	}
+
+	length := len([]rune(password))
+	if p.ASCII {
+		length = len(password)
+	}
+	if p.MinLength > 0 && length < p.MinLength {
+		add(CodeMinLength, map[string]interface{}{"min": p.MinLength})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(CodeMaxLength, map[string]interface{}{"max": p.MaxLength})
+	}
+
+	var digit, lower, upper, uncased, symbol bool
+	for _, r := range password {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case p.ASCII:
+			symbol = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLetter(r):
+			uncased = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireDigit && !digit {
+		add(CodeDigit, nil)
+	}
+	if p.RequireLetter && !lower && !upper && !uncased {
+		add(CodeLetter, nil)
+	}
+	if p.RequireLower && !lower {
+		add(CodeLower, nil)
+	}
+	if p.RequireUpper && !upper {
+		add(CodeUpper, nil)
+	}
+	if p.RequireSymbol && !symbol {
+		add(CodeSymbol, nil)
+	}
+
+	if p.MaxRepeat > 0 && maxRun(password) > p.MaxRepeat {
+		add(CodeRepeat, map[string]interface{}{"max": p.MaxRepeat})
+	}
+	if p.isBanned(password) {
+		add(CodeBanned, nil)
+	}
+	if p.CheckSimilarity && similar(password, identities) {
+		add(CodeSimilar, nil)
+	}
+	if p.MinEntropy > 0 && Entropy(password) < p.MinEntropy {
+		add(CodeEntropy, map[string]interface{}{"min": p.MinEntropy})
+	}
+	return vs
+}
+
+// Validate is Check returning an error, nil or Violations.
+func (p *Policy) Validate(password string, identities ...string) error {
+	if vs := p.Check(password, identities...); len(vs) > 0 {
+		return vs
+	}
+	return nil
+}
+
+func maxRun(s string) int {
+	var (
+		best, run int
+		last      rune = -1
+	)
+	for _, r := range s {
+		if r == last {
+			run++
+		} else {
+			run = 1
+			last = r
+		}
+		if run > best {
+			best = run
+		}
+	}
+	return best
+}
+
+func similar(password string, identities []string) bool {
+	pw := strings.ToLower(password)
+	for _, id := range identities {
+		id = strings.ToLower(strings.TrimSpace(id))
+		candidates := []string{id}
+		if i := strings.IndexByte(id, '@'); i > 0 {
+			candidates = append(candidates, id[:i])
+		}
+		for _, c := range candidates {
+			if len([]rune(c)) >= 3 && (strings.Contains(pw, c) || len([]rune(pw)) >= 3 && strings.Contains(c, pw)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Entropy estimates the entropy of the password in bits as length * log2(pool),
+// the pool being the size of the character classes it uses. Repeated characters
+// only count once per run, so "aaaaaaaa" scores like "a".
+func Entropy(password string) float64 {
+	var (
+		pool                           float64
+		digit, lower, upper, symbol, o bool
+		length                         int
+		last                           rune = -1
+	)
+	for _, r := range password {
+		if r != last {
+			length++
+			last = r
+		}
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			o = true
+		}
+	}
+	if digit {
+		pool += 10
+	}
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if symbol {
+		pool += 33
+	}
+	if o {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(length) * math.Log2(pool)
+}
@@ -0,0 +1,39 @@
+package password
+
+import "testing"
+
+func TestPolicyCheck(t *testing.T) {
+	mixed := &Policy{MinLength: 8, RequireDigit: true, RequireLower: true, RequireUpper: true}
+	letter := &Policy{MinLength: 8, RequireDigit: true, RequireLetter: true}
+	tests := []struct {
+		policy   *Policy
+		password string
+		codes    []string
+	}{
+		{mixed, "Passw0rdX", nil},
+		{mixed, "passw0rdx", []string{CodeUpper}},
+		{mixed, "密码密码密码12", []string{CodeLower, CodeUpper}},
+		{mixed, "密码密码密码1a", []string{CodeUpper}},
+		{letter, "密码密码密码12", nil},
+		{letter, "ab1中文", []string{CodeMinLength}},
+		{letter, "12345678", []string{CodeLetter}},
+		// DefaultPolicy keeps the byte length and ASCII classes of tools.Tool.CheckPasswordLevel.
+		{DefaultPolicy, "ab1中文", nil},
+		{DefaultPolicy, "密码密码密码12", []string{CodeLetter}},
+		{DefaultPolicy, "ＡＢＣ１２３４５", []string{CodeDigit, CodeLetter}},
+		{DefaultPolicy, "abc12", []string{CodeMinLength}},
+		{DefaultPolicy, "abcd1234", nil},
+	}
+	for _, tt := range tests {
+		vs := tt.policy.Check(tt.password)
+		if len(vs) != len(tt.codes) {
+			t.Errorf("%q: expected %v but got %v", tt.password, tt.codes, vs)
+			continue
+		}
+		for _, code := range tt.codes {
+			if !vs.Has(code) {
+				t.Errorf("%q: expected %v but got %v", tt.password, tt.codes, vs)
+			}
+		}
+	}
+}
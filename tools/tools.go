@@ -9,6 +9,7 @@ import (
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 	"github.com/griffin702/service/captcha"
+	"github.com/griffin702/service/password"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"golang.org/x/text/transform"
@@ -489,23 +490,9 @@ func (t *Tool) Substr2(str string, start int, end int) string {
 	return string(rs[start:end])
 }
 
+// CheckPasswordLevel 检查密码强度，规则见password.DefaultPolicy
 func (t *Tool) CheckPasswordLevel(ps string) error {
-	if len(ps) < 8 {
-		return fmt.Errorf("password len is < 8")
-	}
-	num := `[0-9]{1}`
-	az := `[A-Za-z]{1}`
-	//symbol := `[!@#~$%^&*()+|_]{1}`
-	if b, err := regexp.MatchString(num, ps); !b || err != nil {
-		return fmt.Errorf("password need num :%v", err)
-	}
-	if b, err := regexp.MatchString(az, ps); !b || err != nil {
-		return fmt.Errorf("password need A_Z :%v", err)
-	}
-	//if b, err := regexp.MatchString(symbol, ps); !b || err != nil {
-	//	return fmt.Errorf("password need symbol :%v", err)
-	//}
-	return nil
+	return password.DefaultPolicy.Validate(ps)
 }
 
 func (t *Tool) CheckUserName(uname string) (err error) {
@@ -553,11 +540,67 @@ func (t *Tool) VerifyEmailFormat(email string) bool {
 	return reg.MatchString(email)
 }
 
+var (
+	mobilePrefixesMu sync.RWMutex
+	// mobilePrefixes 大陆运营商的号段，见AddMobilePrefixes
+	mobilePrefixes = map[string]bool{}
+)
+
+func init() {
+	AddMobilePrefixes(
+		// China Mobile
+		"134", "135", "136", "137", "138", "139", "147", "148", "150", "151", "152", "157", "158", "159",
+		"172", "178", "182", "183", "184", "187", "188", "195", "197", "198",
+		// China Unicom
+		"130", "131", "132", "145", "146", "155", "156", "166", "167", "175", "176", "185", "186", "196",
+		// China Telecom
+		"133", "149", "153", "173", "177", "180", "181", "189", "190", "191", "193", "199",
+		// China Broadnet
+		"192",
+		// Mobile virtual network operators
+		"162", "165", "170", "171",
+	)
+}
+
+// AddMobilePrefixes 添加VerifyMobileFormat接受的号段，如"19"或"1740"，
+// 号码以其中任一号段开头即视为有效
+func AddMobilePrefixes(prefixes ...string) {
+	mobilePrefixesMu.Lock()
+	defer mobilePrefixesMu.Unlock()
+	for _, p := range prefixes {
+		mobilePrefixes[p] = true
+	}
+}
+
+// MobilePrefixes 返回已知的全部号段
+func MobilePrefixes() []string {
+	mobilePrefixesMu.RLock()
+	defer mobilePrefixesMu.RUnlock()
+	prefixes := make([]string, 0, len(mobilePrefixes))
+	for p := range mobilePrefixes {
+		prefixes = append(prefixes, p)
+	}
+	return prefixes
+}
+
 //mobile verify
 func (t *Tool) VerifyMobileFormat(mobileNum string) bool {
-	regular := "^((13[0-9])|(14[5,7])|(15[0-3,5-9])|(17[0,3,5-8])|(18[0-9])|166|198|199|(147))\\d{8}$"
-	reg := regexp.MustCompile(regular)
-	return reg.MatchString(mobileNum)
+	if len(mobileNum) != 11 {
+		return false
+	}
+	for _, c := range mobileNum {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	mobilePrefixesMu.RLock()
+	defer mobilePrefixesMu.RUnlock()
+	for i := 1; i < len(mobileNum); i++ {
+		if mobilePrefixes[mobileNum[:i]] {
+			return true
+		}
+	}
+	return false
 }
 
 //金额转大写
@@ -0,0 +1,324 @@
+// Package totp implements time-based one-time passwords (RFC 6238) for two-factor
+// authentication: secrets, otpauth:// URIs, QR codes, verification with replay
+// prevention and bcrypt hashed recovery codes.
+package totp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"image/png"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/griffin702/service/captcha"
+	"github.com/griffin702/service/tools"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Algorithm is the HMAC hash function of the codes.
+type Algorithm string
+
+// The algorithms of RFC 6238, most authenticator apps only support SHA1.
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+var (
+	// ErrInvalidSecret is returned when the secret is not valid base32.
+	ErrInvalidSecret = errors.New("totp: invalid secret")
+	// ErrCodeReused is returned when a code that was already accepted is presented again.
+	ErrCodeReused = errors.New("totp: code already used")
+	// ErrInvalidPeriod is returned when the period of the Options is shorter than a second.
+	ErrInvalidPeriod = errors.New("totp: period must be at least one second")
+	// ErrInvalidDigits is returned when the digits of the Options are not between 6 and 8.
+	ErrInvalidDigits = errors.New("totp: digits must be between 6 and 8")
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Options of the generated codes, the zero value means
+// 6 digits, a 30 seconds period and SHA1.
+type Options struct {
+	// Digits is the length of a code, from 6 to 8.
+	// Default: 6
+	Digits int
+	// Period is the lifetime of a code, at least a second, counted in whole seconds.
+	// Default: 30 seconds
+	Period    time.Duration
+	Algorithm Algorithm
+	// Skew is the number of periods accepted before and after the current one.
+	// Default: 1
+	Skew int
+	// NoSkew only accepts the code of the current period, Skew is ignored.
+	// Default: false
+	NoSkew bool
+}
+
+func (o Options) withDefaults() (Options, error) {
+	if o.Digits == 0 {
+		o.Digits = 6
+	} else if o.Digits < 6 || o.Digits > 8 {
+		return o, ErrInvalidDigits
+	}
+	if o.Period == 0 {
+		o.Period = 30 * time.Second
+	} else if o.Period < time.Second {
+		return o, ErrInvalidPeriod
+	}
+	if o.Algorithm == "" {
+		o.Algorithm = AlgorithmSHA1
+	}
+	if o.NoSkew || o.Skew < 0 {
+		o.Skew = 0
+	} else if o.Skew == 0 {
+		o.Skew = 1
+	}
+	return o, nil
+}
+
+func (o Options) hash() func() hash.Hash {
+	switch o.Algorithm {
+	case AlgorithmSHA256:
+		return sha256.New
+	case AlgorithmSHA512:
+		return sha512.New
+	}
+	return sha1.New
+}
+
+// GenerateSecret returns a random base32 secret of size bytes, 20 when size is 0.
+func GenerateSecret(size int) (string, error) {
+	if size <= 0 {
+		size = 20
+	}
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	key, err := b32.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// URI builds the otpauth:// URI understood by authenticator apps.
+func URI(secret, issuer, account string, opts ...Options) (string, error) {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	o, err := o.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	q := url.Values{}
+	q.Set("secret", secret)
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+	q.Set("algorithm", string(o.Algorithm))
+	q.Set("digits", strconv.Itoa(o.Digits))
+	q.Set("period", strconv.Itoa(int(o.Period/time.Second)))
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: q.Encode()}
+	return u.String(), nil
+}
+
+// QRCode renders the URI as a size x size PNG, use ToBase64String to embed it in a page.
+func QRCode(uri string, size int) (image captcha.Image, err error) {
+	q, err := qrcode.New(uri, qrcode.Medium)
+	if err != nil {
+		return
+	}
+	buf := new(bytes.Buffer)
+	if err = png.Encode(buf, q.Image(size)); err != nil {
+		return
+	}
+	image = buf.Bytes()
+	return
+}
+
+// Code returns the code of the secret at time t.
+func Code(secret string, t time.Time, opts ...Options) (string, error) {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	o, err := o.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return generate(key, counterAt(t, o.Period), o), nil
+}
+
+func counterAt(t time.Time, period time.Duration) uint64 {
+	return uint64(t.Unix() / int64(period/time.Second))
+}
+
+func generate(key []byte, counter uint64, o Options) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(o.hash(), key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < o.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", o.Digits, value%mod)
+}
+
+// UsedCodeStore remembers the last accepted time step of each account,
+// so a code can not be used twice.
+type UsedCodeStore interface {
+	// LastCounter returns the last accepted time step of the account, 0 if none.
+	LastCounter(account string) uint64
+	// SetLastCounter records the accepted time step of the account.
+	SetLastCounter(account string, counter uint64)
+}
+
+// MemoryUsedCodeStore is a UsedCodeStore keeping the time steps in memory.
+type MemoryUsedCodeStore struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// NewMemoryUsedCodeStore returns an empty in-memory store.
+func NewMemoryUsedCodeStore() *MemoryUsedCodeStore {
+	return &MemoryUsedCodeStore{counters: make(map[string]uint64)}
+}
+
+// LastCounter implements UsedCodeStore.
+func (s *MemoryUsedCodeStore) LastCounter(account string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[account]
+}
+
+// SetLastCounter implements UsedCodeStore.
+func (s *MemoryUsedCodeStore) SetLastCounter(account string, counter uint64) {
+	s.mu.Lock()
+	s.counters[account] = counter
+	s.mu.Unlock()
+}
+
+// Verifier checks codes within the drift window and rejects replays.
+type Verifier struct {
+	Options Options
+	// Used records the accepted codes.
+	// Default: NewMemoryUsedCodeStore()
+	Used UsedCodeStore
+
+	mu sync.Mutex
+}
+
+// NewVerifier constructs a new Verifier with supplied options.
+func NewVerifier(opts ...Options) *Verifier {
+	v := &Verifier{Used: NewMemoryUsedCodeStore()}
+	if len(opts) > 0 {
+		v.Options = opts[0]
+	}
+	return v
+}
+
+// Verify reports whether code is valid for the secret of the account at time now.
+// An accepted code, or any older one, returns ErrCodeReused afterwards.
+func (v *Verifier) Verify(account, secret, code string, now time.Time) (bool, error) {
+	o, err := v.Options.withDefaults()
+	if err != nil {
+		return false, err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return false, err
+	}
+	if len(code) != o.Digits {
+		return false, nil
+	}
+
+	current := counterAt(now, o.Period)
+	for i := -o.Skew; i <= o.Skew; i++ {
+		counter := current + uint64(i)
+		if i < 0 && current < uint64(-i) {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(generate(key, counter, o)), []byte(code)) != 1 {
+			continue
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if counter <= v.Used.LastCounter(account) {
+			return false, ErrCodeReused
+		}
+		v.Used.SetLastCounter(account, counter)
+		return true, nil
+	}
+	return false, nil
+}
+
+// GenerateRecoveryCodes returns n one-time recovery codes of the form
+// "xxxxx-xxxxx" and their bcrypt hashes, only the hashes should be stored.
+func GenerateRecoveryCodes(n int) (codes, hashes []string, err error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	for i := 0; i < n; i++ {
+		b := make([]byte, 10)
+		for j := range b {
+			k, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return nil, nil, err
+			}
+			b[j] = alphabet[k.Int64()]
+		}
+		c := string(b[:5]) + "-" + string(b[5:])
+		h := tools.Tools.BcryptHashGenerate(c)
+		if h == "" {
+			return nil, nil, errors.New("totp: failed to hash recovery code")
+		}
+		codes = append(codes, c)
+		hashes = append(hashes, h)
+	}
+	return codes, hashes, nil
+}
+
+// MatchRecoveryCode returns the index of the hash matching code, or -1.
+// The caller must remove the matched hash so the code can not be used twice.
+func MatchRecoveryCode(hashes []string, code string) int {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for i, h := range hashes {
+		if tools.Tools.BcryptHashCompare(h, code) {
+			return i
+		}
+	}
+	return -1
+}
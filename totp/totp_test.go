@@ -0,0 +1,223 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret returns the base32 form of the ASCII seeds of RFC 6238 Appendix B.
+func rfcSecret(seed string) string {
+	return base32.StdEncoding.EncodeToString([]byte(seed))
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	secrets := map[Algorithm]string{
+		AlgorithmSHA1:   rfcSecret("12345678901234567890"),
+		AlgorithmSHA256: rfcSecret("12345678901234567890123456789012"),
+		AlgorithmSHA512: rfcSecret("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix int64
+		sha1 string
+		s256 string
+		s512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+	for _, tt := range tests {
+		for alg, want := range map[Algorithm]string{AlgorithmSHA1: tt.sha1, AlgorithmSHA256: tt.s256, AlgorithmSHA512: tt.s512} {
+			code, err := Code(secrets[alg], time.Unix(tt.unix, 0), Options{Digits: 8, Algorithm: alg})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != want {
+				t.Errorf("%s at %d: expected %s but got %s", alg, tt.unix, want, code)
+			}
+		}
+	}
+
+	// The default 6 digits are the last 6 digits of the 8 digits code.
+	code, err := Code(secrets[AlgorithmSHA1], time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Fatalf("expected 287082 but got %s", code)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	secret := rfcSecret("12345678901234567890")
+	tests := []struct {
+		opts Options
+		err  error
+	}{
+		{Options{}, nil},
+		{Options{Digits: 6}, nil},
+		{Options{Digits: 8}, nil},
+		{Options{Digits: 5}, ErrInvalidDigits},
+		{Options{Digits: 9}, ErrInvalidDigits},
+		{Options{Digits: 10}, ErrInvalidDigits},
+		{Options{Digits: -1}, ErrInvalidDigits},
+		{Options{Period: time.Millisecond}, ErrInvalidPeriod},
+		{Options{Period: -time.Second}, ErrInvalidPeriod},
+	}
+	for _, tt := range tests {
+		if _, err := Code(secret, time.Now(), tt.opts); err != tt.err {
+			t.Errorf("%+v: expected %v but got %v", tt.opts, tt.err, err)
+		}
+		if _, err := NewVerifier(tt.opts).Verify("alice", secret, "123456", time.Now()); err != tt.err {
+			t.Errorf("%+v: expected %v from Verify but got %v", tt.opts, tt.err, err)
+		}
+	}
+	if _, err := Code("not base32!", time.Now()); err != ErrInvalidSecret {
+		t.Fatalf("expected ErrInvalidSecret but got %v", err)
+	}
+}
+
+func TestVerifySkew(t *testing.T) {
+	secret, err := GenerateSecret(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	codeAt := func(offset time.Duration) string {
+		code, err := Code(secret, now.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	tests := []struct {
+		name   string
+		opts   Options
+		offset time.Duration
+		ok     bool
+	}{
+		{"current", Options{}, 0, true},
+		{"previous", Options{}, -30 * time.Second, true},
+		{"next", Options{}, 30 * time.Second, true},
+		{"two periods ago", Options{}, -60 * time.Second, false},
+		{"two periods ahead", Options{}, 60 * time.Second, false},
+		{"skew 2", Options{Skew: 2}, -60 * time.Second, true},
+		{"no skew current", Options{NoSkew: true}, 0, true},
+		{"no skew previous", Options{NoSkew: true}, -30 * time.Second, false},
+		{"no skew next", Options{NoSkew: true, Skew: 2}, 30 * time.Second, false},
+	}
+	for _, tt := range tests {
+		ok, err := NewVerifier(tt.opts).Verify("alice", secret, codeAt(tt.offset), now)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ok != tt.ok {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.ok, ok)
+		}
+	}
+
+	v := NewVerifier()
+	if ok, _ := v.Verify("alice", secret, codeAt(0)[:5], now); ok {
+		t.Fatal("expected a short code to be rejected")
+	}
+	if ok, _ := v.Verify("alice", secret, "", now); ok {
+		t.Fatal("expected an empty code to be rejected")
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	secret, err := GenerateSecret(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	current, _ := Code(secret, now)
+	previous, _ := Code(secret, now.Add(-30*time.Second))
+
+	v := NewVerifier()
+	if ok, err := v.Verify("alice", secret, current, now); !ok || err != nil {
+		t.Fatalf("expected the code to be accepted but got %v, %v", ok, err)
+	}
+	if ok, err := v.Verify("alice", secret, current, now); ok || err != ErrCodeReused {
+		t.Fatalf("expected ErrCodeReused but got %v, %v", ok, err)
+	}
+	// An older code of the window is a replay too.
+	if ok, err := v.Verify("alice", secret, previous, now); ok || err != ErrCodeReused {
+		t.Fatalf("expected ErrCodeReused for an older code but got %v, %v", ok, err)
+	}
+	// The accepted time steps are tracked per account.
+	if ok, err := v.Verify("bob", secret, current, now); !ok || err != nil {
+		t.Fatalf("expected the code of another account to be accepted but got %v, %v", ok, err)
+	}
+	next, _ := Code(secret, now.Add(30*time.Second))
+	if ok, err := v.Verify("alice", secret, next, now.Add(30*time.Second)); !ok || err != nil {
+		t.Fatalf("expected the next code to be accepted but got %v, %v", ok, err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := URI("JBSWY3DPEHPK3PXP", "Example Co", "alice@example.com", Options{Digits: 8, Period: time.Minute, Algorithm: AlgorithmSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Example Co:alice@example.com" {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+	q := u.Query()
+	for k, want := range map[string]string{"secret": "JBSWY3DPEHPK3PXP", "issuer": "Example Co", "algorithm": "SHA256", "digits": "8", "period": "60"} {
+		if q.Get(k) != want {
+			t.Errorf("%s: expected %q but got %q", k, want, q.Get(k))
+		}
+	}
+
+	uri, err = URI("JBSWY3DPEHPK3PXP", "", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "otpauth://totp/alice?algorithm=SHA1&digits=6&period=30&secret=JBSWY3DPEHPK3PXP"; uri != want {
+		t.Fatalf("expected %s but got %s", want, uri)
+	}
+	if _, err = URI("JBSWY3DPEHPK3PXP", "", "alice", Options{Digits: 10}); err != ErrInvalidDigits {
+		t.Fatalf("expected ErrInvalidDigits but got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 3 || len(hashes) != 3 {
+		t.Fatalf("expected 3 codes and hashes but got %d and %d", len(codes), len(hashes))
+	}
+	for i, c := range codes {
+		if len(c) != 11 || c[5] != '-' || strings.ContainsAny(c, "01ilo") {
+			t.Errorf("unexpected code %q", c)
+		}
+		if hashes[i] == c {
+			t.Errorf("code %d stored in clear", i)
+		}
+	}
+	if i := MatchRecoveryCode(hashes, codes[1]); i != 1 {
+		t.Fatalf("expected index 1 but got %d", i)
+	}
+	if i := MatchRecoveryCode(hashes, "  "+strings.ToUpper(codes[2])+"\n"); i != 2 {
+		t.Fatalf("expected index 2 for an upper case code but got %d", i)
+	}
+	if i := MatchRecoveryCode(hashes, "aaaaa-aaaaa"); i != -1 {
+		t.Fatalf("expected -1 but got %d", i)
+	}
+	if i := MatchRecoveryCode(nil, codes[0]); i != -1 {
+		t.Fatalf("expected -1 without hashes but got %d", i)
+	}
+}
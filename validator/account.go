@@ -0,0 +1,104 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/griffin702/service/password"
+	"github.com/griffin702/service/tools"
+)
+
+// DefaultPasswordLevel is the level of password_level without param,
+// the policy of tools.Tool.CheckPasswordLevel.
+const DefaultPasswordLevel = "default"
+
+var (
+	passwordPoliciesMu sync.RWMutex
+	passwordPolicies   = map[string]*password.Policy{
+		DefaultPasswordLevel: password.DefaultPolicy,
+		"strong": {
+			MinLength:     10,
+			RequireDigit:  true,
+			RequireLower:  true,
+			RequireUpper:  true,
+			RequireSymbol: true,
+			MaxRepeat:     3,
+		},
+	}
+)
+
+// RegisterPasswordLevel adds or replaces the policy of a level of the password_level tag,
+// e.g. RegisterPasswordLevel("admin", policy) for `valid:"password_level=admin"`.
+// The levels "default" and "strong" are predefined.
+func RegisterPasswordLevel(level string, policy *password.Policy) {
+	passwordPoliciesMu.Lock()
+	passwordPolicies[level] = policy
+	passwordPoliciesMu.Unlock()
+}
+
+func passwordPolicy(level string) *password.Policy {
+	if level == "" {
+		level = DefaultPasswordLevel
+	}
+	passwordPoliciesMu.RLock()
+	defer passwordPoliciesMu.RUnlock()
+	return passwordPolicies[level]
+}
+
+func checkUserName(s string) bool {
+	return tools.Tools.CheckUserName(s) == nil
+}
+
+func checkNickName(s string) bool {
+	return tools.Tools.CheckNickName(s) == nil
+}
+
+// checkPasswordLevel is the password_level tag, an unknown level is invalid.
+func checkPasswordLevel(fl validator.FieldLevel) bool {
+	p := passwordPolicy(fl.Param())
+	if p == nil || fl.Field().Kind() != reflect.String {
+		return false
+	}
+	if p == password.DefaultPolicy {
+		return tools.Tools.CheckPasswordLevel(fl.Field().String()) == nil
+	}
+	return p.Validate(fl.Field().String()) == nil
+}
+
+// passwordLevelParams passes the violations of the policy as {1}.
+func passwordLevelParams(fe validator.FieldError, locale string) []string {
+	var reason string
+	if p := passwordPolicy(fe.Param()); p != nil {
+		if s, ok := fe.Value().(string); ok {
+			reason = strings.Join(p.Check(s).Translate(locale), ", ")
+		}
+	}
+	return []string{reason}
+}
+
+func init() {
+	builtins = append(builtins,
+		builtin{tag: "username", fn: stringFunc(checkUserName), templates: map[string]string{
+			"zh":      "{0}必须是6到30位字母、数字或下划线，且不能是纯数字",
+			"en":      "{0} must be 6 to 30 letters, digits or underscores and not only digits",
+			"zh_Hant": "{0}必須是6到30位字母、數字或底線，且不能是純數字",
+		}},
+		builtin{tag: "nickname", fn: stringFunc(checkNickName), templates: map[string]string{
+			"zh":      "{0}不能包含空格或特殊字符",
+			"en":      "{0} must not contain spaces or symbols",
+			"zh_Hant": "{0}不能包含空格或特殊字元",
+		}},
+		builtin{tag: "cn_email", fn: stringFunc(tools.Tools.VerifyEmailFormat), templates: map[string]string{
+			"zh":      "{0}必须是有效的邮箱地址",
+			"en":      "{0} must be a valid email address",
+			"zh_Hant": "{0}必須是有效的電子郵件地址",
+		}},
+		builtin{tag: "password_level", fn: checkPasswordLevel, templates: map[string]string{
+			"zh":      "{0}强度不足：{1}",
+			"en":      "{0} is too weak: {1}",
+			"zh_Hant": "{0}強度不足：{1}",
+		}, params: passwordLevelParams},
+	)
+}
@@ -0,0 +1,89 @@
+package validator
+
+import (
+	"strconv"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// builtin is a validation registered by NewValidator.
+type builtin struct {
+	tag string
+	fn  validator.Func
+	// templates are the messages per locale, see registerTranslation.
+	templates map[string]string
+	// params returns the parameters of the messages, nil passes the param of the tag as {1}.
+	params paramsFunc
+	// callValidationEvenIfNull runs fn for nil pointer fields too, instead of failing them.
+	callValidationEvenIfNull bool
+}
+
+// paramsFunc returns the parameters {1}, {2}... of the message of a failed rule in a locale.
+type paramsFunc func(fe validator.FieldError, locale string) []string
+
+// builtins are registered in order by NewValidator.
+var builtins []builtin
+
+// registerBuiltins registers the builtins and their translations.
+func (e *engine) registerBuiltins() {
+	for _, b := range builtins {
+		_ = e.validate.RegisterValidation(b.tag, b.fn, b.callValidationEvenIfNull)
+		_ = e.registerTranslation(b.tag, b.templates, b.params)
+	}
+}
+
+// registerTranslation registers the message templates of the tag for every
+// locale of the engine, replacing the existing ones. The keys of templates
+// are language tags like "zh-CN" or "en", a locale without template uses the
+// "en" one. {0} is replaced by the field and {1}, {2}... by the result of
+// params, in any order.
+func (e *engine) registerTranslation(tag string, templates map[string]string, params paramsFunc) error {
+	if e.uni == nil || len(templates) == 0 {
+		return nil
+	}
+	if params == nil {
+		params = tagParam
+	}
+	normalized := make(map[string]string, len(templates))
+	for lang, tpl := range templates {
+		if l := supportedLocale(lang); l != "" {
+			normalized[l] = tpl
+		}
+	}
+	for _, l := range e.locales {
+		tpl, ok := normalized[l]
+		if !ok {
+			if tpl, ok = normalized["en"]; !ok {
+				continue
+			}
+		}
+		trans, _ := e.uni.GetTranslator(l)
+		err := e.validate.RegisterTranslation(tag, trans, func(ut.Translator) error {
+			return nil
+		}, func(trans ut.Translator, fe validator.FieldError) string {
+			return formatMessage(tpl, append([]string{fe.Field()}, params(fe, trans.Locale())...))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tagParam(fe validator.FieldError, _ string) []string {
+	return []string{fe.Param()}
+}
+
+// formatMessage replaces the placeholders {0}, {1}... of tpl by args.
+func formatMessage(tpl string, args []string) string {
+	if !strings.Contains(tpl, "{") {
+		return tpl
+	}
+	pairs := make([]string, 0, 2*len(args))
+	for i, a := range args {
+		pairs = append(pairs, "{"+strconv.Itoa(i)+"}", a)
+	}
+	return strings.NewReplacer(pairs...).Replace(tpl)
+}
@@ -0,0 +1,172 @@
+package validator
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/griffin702/service/tools"
+)
+
+// AddMobilePrefixes adds number segments accepted by cn_mobile, e.g. "19" or "1740".
+// A number is accepted when one of the segments is a prefix of it.
+// The segments are shared with tools.Tool.VerifyMobileFormat.
+func AddMobilePrefixes(prefixes ...string) {
+	tools.AddMobilePrefixes(prefixes...)
+}
+
+// IsCNMobile reports whether s is an 11-digit mainland China mobile number of a known segment.
+func IsCNMobile(s string) bool {
+	return tools.Tools.VerifyMobileFormat(s)
+}
+
+var (
+	idCardWeights   = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	idCardChecks    = "10X98765432"
+	idCardProvinces = map[string]bool{
+		"11": true, "12": true, "13": true, "14": true, "15": true,
+		"21": true, "22": true, "23": true,
+		"31": true, "32": true, "33": true, "34": true, "35": true, "36": true, "37": true,
+		"41": true, "42": true, "43": true, "44": true, "45": true, "46": true,
+		"50": true, "51": true, "52": true, "53": true, "54": true,
+		"61": true, "62": true, "63": true, "64": true, "65": true,
+		"71": true, "81": true, "82": true, "83": true,
+	}
+)
+
+// IsCNIDCard reports whether s is an 18-digit resident identity card number
+// with a known province, a valid birth date and a valid check digit (GB 11643).
+func IsCNIDCard(s string) bool {
+	if len(s) != 18 || !isDigits(s[:17]) || !idCardProvinces[s[:2]] {
+		return false
+	}
+	birth, err := time.ParseInLocation("20060102", s[6:14], time.Local)
+	if err != nil || birth.Year() < 1900 || birth.After(time.Now()) {
+		return false
+	}
+	sum := 0
+	for i, w := range idCardWeights {
+		sum += int(s[i]-'0') * w
+	}
+	return strings.ToUpper(s[17:]) == string(idCardChecks[sum%11])
+}
+
+const usccChars = "0123456789ABCDEFGHJKLMNPQRTUWXY"
+
+var usccWeights = [17]int{1, 3, 9, 27, 19, 26, 16, 17, 20, 29, 25, 13, 8, 24, 10, 30, 28}
+
+// IsCNUSCC reports whether s is an 18-character unified social credit code
+// with a valid check character (GB 32100).
+func IsCNUSCC(s string) bool {
+	if len(s) != 18 {
+		return false
+	}
+	s = strings.ToUpper(s)
+	sum := 0
+	for i, w := range usccWeights {
+		n := strings.IndexByte(usccChars, s[i])
+		if n < 0 {
+			return false
+		}
+		sum += n * w
+	}
+	check := (31 - sum%31) % 31
+	return s[17] == usccChars[check]
+}
+
+// IsCNBankCard reports whether s is a 12 to 19-digit card number passing the Luhn check.
+func IsCNBankCard(s string) bool {
+	if len(s) < 12 || len(s) > 19 || !isDigits(s) {
+		return false
+	}
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		d := int(s[i] - '0')
+		if (len(s)-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+const postcodePattern = `^[0-8][0-9]{5}$`
+
+var postcodeRegexp = regexp.MustCompile(postcodePattern)
+
+// IsCNPostcode reports whether s is a 6-digit mainland China postcode.
+func IsCNPostcode(s string) bool {
+	return postcodeRegexp.MatchString(s)
+}
+
+// platePattern accepts the lowercase letters too, for the schemas,
+// IsCNPlate uppercases the plate first.
+const platePattern = `^[京津沪渝冀豫云辽黑湘皖鲁新苏浙赣鄂桂甘晋蒙陕吉闽贵粤青藏川宁琼][A-HJ-NP-Za-hj-np-z]` +
+	`([A-HJ-NP-Za-hj-np-z0-9]{4}[A-HJ-NP-Za-hj-np-z0-9挂学警港澳]|[DFdf][A-HJ-NP-Za-hj-np-z0-9][0-9]{4}|[0-9]{5}[DFdf])$`
+
+var plateRegexp = regexp.MustCompile(platePattern)
+
+// IsCNPlate reports whether s is a mainland China vehicle plate number,
+// new energy plates included.
+func IsCNPlate(s string) bool {
+	return plateRegexp.MatchString(strings.ToUpper(s))
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// stringFunc adapts a string check to a validator.Func, other kinds of fields are invalid.
+func stringFunc(fn func(string) bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		field := fl.Field()
+		if field.Kind() != reflect.String {
+			return false
+		}
+		return fn(field.String())
+	}
+}
+
+func init() {
+	builtins = append(builtins,
+		builtin{tag: "cn_mobile", fn: stringFunc(IsCNMobile), templates: map[string]string{
+			"zh":      "{0}必须是有效的手机号码",
+			"en":      "{0} must be a valid mobile number",
+			"zh_Hant": "{0}必須是有效的手機號碼",
+		}},
+		builtin{tag: "cn_idcard", fn: stringFunc(IsCNIDCard), templates: map[string]string{
+			"zh":      "{0}必须是有效的身份证号码",
+			"en":      "{0} must be a valid ID card number",
+			"zh_Hant": "{0}必須是有效的身分證號碼",
+		}},
+		builtin{tag: "cn_uscc", fn: stringFunc(IsCNUSCC), templates: map[string]string{
+			"zh":      "{0}必须是有效的统一社会信用代码",
+			"en":      "{0} must be a valid unified social credit code",
+			"zh_Hant": "{0}必須是有效的統一社會信用代碼",
+		}},
+		builtin{tag: "cn_bankcard", fn: stringFunc(IsCNBankCard), templates: map[string]string{
+			"zh":      "{0}必须是有效的银行卡号",
+			"en":      "{0} must be a valid bank card number",
+			"zh_Hant": "{0}必須是有效的銀行卡號",
+		}},
+		builtin{tag: "cn_postcode", fn: stringFunc(IsCNPostcode), templates: map[string]string{
+			"zh":      "{0}必须是有效的邮政编码",
+			"en":      "{0} must be a valid postcode",
+			"zh_Hant": "{0}必須是有效的郵遞區號",
+		}},
+		builtin{tag: "cn_plate", fn: stringFunc(IsCNPlate), templates: map[string]string{
+			"zh":      "{0}必须是有效的车牌号码",
+			"en":      "{0} must be a valid plate number",
+			"zh_Hant": "{0}必須是有效的車牌號碼",
+		}},
+	)
+}
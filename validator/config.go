@@ -0,0 +1,74 @@
+package validator
+
+import (
+	"reflect"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	"github.com/go-playground/locales/zh_Hant"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	zhTwTranslations "github.com/go-playground/validator/v10/translations/zh_tw"
+)
+
+// DefaultTagName is the struct tag of the rules.
+const DefaultTagName = "valid"
+
+// LabelTag is the struct tag of the name of a field in the messages,
+// e.g. `label:"用户名" valid:"required"` gives "用户名为必填字段".
+const LabelTag = "label"
+
+// displayName is the name of the field in the messages, "" for the Go name.
+func displayName(f reflect.StructField) string {
+	if label := f.Tag.Get(LabelTag); label != "" {
+		return label
+	}
+	return f.Tag.Get(keyTag)
+}
+
+// Config is a struct for specifying configuration options of the Validator.
+type Config struct {
+	// TagName is the struct tag holding the rules.
+	// Default: "valid"
+	TagName string
+	// DisableTranslation returns the untranslated messages of the validator
+	// package, e.g. "Key: 'X.Name' Error:Field validation for 'Name' failed on the 'required' tag".
+	// Default: false
+	DisableTranslation bool
+	// Locales are the locales of the messages, the first one is the fallback
+	// of the unsupported locales. The supported locales are zh, en and zh_Hant,
+	// the other ones are ignored.
+	// Default: []string{"zh", "en", "zh_Hant"}
+	Locales []string
+	// Sanitize applies the sanitize tags before the validation, modifying the
+	// validated structs, see SanitizeTag.
+	// Default: false
+	Sanitize bool
+}
+
+// locale is a supported locale of the messages.
+type locale struct {
+	new      func() locales.Translator
+	register func(v *validator.Validate, trans ut.Translator) error
+}
+
+var supportedLocales = map[string]locale{
+	"zh":      {zh.New, zhTranslations.RegisterDefaultTranslations},
+	"en":      {en.New, enTranslations.RegisterDefaultTranslations},
+	"zh_Hant": {zh_Hant.New, zhTwTranslations.RegisterDefaultTranslations},
+}
+
+var defaultLocales = []string{DefaultLocale, "en", "zh_Hant"}
+
+// supportedLocale returns the supported locale of a language tag, "" if there's none.
+func supportedLocale(tag string) string {
+	for _, l := range normalizeLocale(tag) {
+		if _, ok := supportedLocales[l]; ok {
+			return l
+		}
+	}
+	return ""
+}
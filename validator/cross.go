@@ -0,0 +1,128 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// sibling returns the field of the parent struct at namespace, e.g. "NewPassword"
+// or "Profile.Email", whether the parent is a pointer or not.
+// ok is false when the field does not exist or a pointer on the way is nil.
+func sibling(fl validator.FieldLevel, namespace string) (field reflect.Value, ok bool) {
+	field, _, _, ok = fl.GetStructFieldOKAdvanced2(fl.Parent(), strings.TrimSpace(namespace))
+	return field, ok
+}
+
+// isEmpty reports whether v is the zero value of its type, an empty string, slice or map.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// requiredWithNonempty is the required_with_nonempty=Field tag:
+// when the field is not empty, Field must not be empty either.
+// It fails when Field does not exist.
+func requiredWithNonempty(fl validator.FieldLevel) bool {
+	if isEmpty(indirect(fl.Field())) {
+		return true
+	}
+	other, ok := sibling(fl, fl.Param())
+	return ok && !isEmpty(indirect(other))
+}
+
+// checkNewPassword：当password不为空时，需检查NewPassword也不能为空，password为空时同样不通过
+func checkNewPassword(fl validator.FieldLevel) bool {
+	if isEmpty(indirect(fl.Field())) {
+		return false
+	}
+	np, ok := sibling(fl, "NewPassword")
+	return ok && !isEmpty(indirect(np))
+}
+
+// splitEqIf splits the param of eq_if, "Field:value".
+func splitEqIf(param string) (name, value string) {
+	if i := strings.IndexByte(param, ':'); i >= 0 {
+		return strings.TrimSpace(param[:i]), param[i+1:]
+	}
+	return strings.TrimSpace(param), ""
+}
+
+// eqIf is the eq_if=Field:value tag: when Field equals value,
+// compared as formatted by fmt, the field must not be empty.
+// It fails when Field does not exist.
+func eqIf(fl validator.FieldLevel) bool {
+	name, value := splitEqIf(fl.Param())
+	other, ok := sibling(fl, name)
+	if !ok {
+		return false
+	}
+	if other = indirect(other); !other.IsValid() || fmt.Sprint(other.Interface()) != value {
+		return true
+	}
+	return !isEmpty(indirect(fl.Field()))
+}
+
+// differsFrom is the differs_from=Field tag: the field must not equal Field.
+// It fails when Field does not exist.
+func differsFrom(fl validator.FieldLevel) bool {
+	other, ok := sibling(fl, fl.Param())
+	if !ok {
+		return false
+	}
+	field, other := indirect(fl.Field()), indirect(other)
+	if !field.IsValid() || !other.IsValid() {
+		return field.IsValid() != other.IsValid()
+	}
+	return !reflect.DeepEqual(field.Interface(), other.Interface())
+}
+
+// eqIfParams passes the field of the param as {1} and the value as {2}.
+func eqIfParams(fe validator.FieldError, _ string) []string {
+	name, value := splitEqIf(fe.Param())
+	return []string{name, value}
+}
+
+func init() {
+	builtins = append(builtins,
+		builtin{tag: "required_with_nonempty", fn: requiredWithNonempty, templates: map[string]string{
+			"zh":      "{0}不为空时{1}为必填字段",
+			"en":      "{1} is required when {0} is set",
+			"zh_Hant": "{0}不為空時{1}為必填欄位",
+		}, callValidationEvenIfNull: true},
+		builtin{tag: "ck_np", fn: checkNewPassword, templates: map[string]string{
+			"zh":      "{0}和NewPassword为必填字段",
+			"en":      "{0} and NewPassword are required",
+			"zh_Hant": "{0}和NewPassword為必填欄位",
+		}},
+		builtin{tag: "eq_if", fn: eqIf, templates: map[string]string{
+			"zh":      "{1}为{2}时{0}为必填字段",
+			"en":      "{0} is required when {1} is {2}",
+			"zh_Hant": "{1}為{2}時{0}為必填欄位",
+		}, params: eqIfParams, callValidationEvenIfNull: true},
+		builtin{tag: "differs_from", fn: differsFrom, templates: map[string]string{
+			"zh":      "{0}不能与{1}相同",
+			"en":      "{0} must be different from {1}",
+			"zh_Hant": "{0}不能與{1}相同",
+		}, callValidationEvenIfNull: true},
+	)
+}
@@ -0,0 +1,104 @@
+package validator
+
+import "testing"
+
+type changePassword struct {
+	Password    string `valid:"ck_np"`
+	NewPassword string
+}
+
+type crossFields struct {
+	Kind    string
+	Name    string `valid:"eq_if=Kind:user"`
+	Email   string `valid:"omitempty,required_with_nonempty=Kind"`
+	Backup  string `valid:"differs_from=Email"`
+	Missing string `valid:"omitempty,differs_from=Nope"`
+	Orphan  string `valid:"omitempty,eq_if=Nope:x"`
+	Lonely  string `valid:"omitempty,required_with_nonempty=Nope"`
+}
+
+// pointerFields are the crossFields rules on optional fields, a nil pointer is empty.
+type pointerFields struct {
+	Kind   *string
+	Name   *string `valid:"eq_if=Kind:user"`
+	Email  *string `valid:"required_with_nonempty=Kind"`
+	Backup *string `valid:"differs_from=Email"`
+}
+
+func TestCheckNewPassword(t *testing.T) {
+	v := NewValidator()
+	tests := []struct {
+		obj changePassword
+		ok  bool
+	}{
+		{changePassword{Password: "old", NewPassword: "new"}, true},
+		{changePassword{Password: "old"}, false},
+		{changePassword{NewPassword: "new"}, false},
+		{changePassword{}, false},
+	}
+	for _, tt := range tests {
+		if err := v.ValidateStruct(&tt.obj); (err == nil) != tt.ok {
+			t.Errorf("%+v: expected ok=%v but got %v", tt.obj, tt.ok, err)
+		}
+	}
+}
+
+func TestCrossFieldRules(t *testing.T) {
+	v := NewValidator()
+	tests := []struct {
+		obj   crossFields
+		field string
+	}{
+		{crossFields{Backup: "b"}, ""},
+		{crossFields{Kind: "user", Backup: "b"}, "Name"},
+		{crossFields{Email: "a@b.c", Backup: "b"}, "Email"},
+		{crossFields{Email: "a@b.c", Backup: "a@b.c", Kind: "admin"}, "Backup"},
+		{crossFields{Missing: "x", Backup: "b"}, "Missing"},
+		{crossFields{Orphan: "x", Backup: "b"}, "Orphan"},
+		{crossFields{Lonely: "x", Backup: "b"}, "Lonely"},
+	}
+	for _, tt := range tests {
+		err := v.ValidateStruct(&tt.obj)
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%+v: %v", tt.obj, err)
+			}
+			continue
+		}
+		es, _ := AsFieldErrors(err)
+		if _, ok := es.Get(tt.field); !ok || len(es) != 1 {
+			t.Errorf("%+v: expected %s to fail but got %v", tt.obj, tt.field, err)
+		}
+	}
+}
+
+func TestCrossFieldRulesPointers(t *testing.T) {
+	v := NewValidator()
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		obj   pointerFields
+		field string
+	}{
+		{pointerFields{Backup: str("b")}, ""},
+		{pointerFields{Kind: str("user"), Backup: str("b")}, "Name"},
+		{pointerFields{Kind: str("user"), Name: str("n"), Backup: str("b")}, ""},
+		{pointerFields{Kind: str("admin"), Backup: str("b")}, ""},
+		{pointerFields{Email: str("a@b.c"), Backup: str("b")}, "Email"},
+		{pointerFields{Email: str("a@b.c"), Kind: str("admin")}, ""},
+		{pointerFields{Email: str("a@b.c"), Kind: str("admin"), Backup: str("a@b.c")}, "Backup"},
+		{pointerFields{}, "Backup"},
+	}
+	for _, tt := range tests {
+		err := v.ValidateStruct(&tt.obj)
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%+v: %v", tt.obj, err)
+			}
+			continue
+		}
+		es, _ := AsFieldErrors(err)
+		if _, ok := es.Get(tt.field); !ok || len(es) != 1 {
+			t.Errorf("%+v: expected %s to fail but got %v", tt.obj, tt.field, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package validator
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a failed rule of a field.
+type FieldError struct {
+	// Field is the path of the field using the json names, e.g. "user.emails[0]".
+	Field string `json:"field"`
+	// Tag is the rule that failed, e.g. "required".
+	Tag string `json:"tag"`
+	// Param is the parameter of the rule, e.g. "3" for "min=3".
+	Param string `json:"param,omitempty"`
+	// Message is the translated message.
+	Message string `json:"message"`
+}
+
+// Error implements error.
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// FieldErrors are all the failed rules of a validation, in the order of the fields.
+type FieldErrors []FieldError
+
+// Error implements error, the messages are joined with ", ".
+func (es FieldErrors) Error() string {
+	list := make([]string, 0, len(es))
+	for _, e := range es {
+		list = append(list, e.Message)
+	}
+	return strings.Join(list, ", ")
+}
+
+// MarshalJSON encodes the errors as {"errors": [{"field": ..., "tag": ..., "param": ..., "message": ...}]}.
+func (es FieldErrors) MarshalJSON() ([]byte, error) {
+	list := []FieldError(es)
+	if list == nil {
+		list = []FieldError{}
+	}
+	return json.Marshal(struct {
+		Errors []FieldError `json:"errors"`
+	}{list})
+}
+
+// Get returns the first error of the field path.
+func (es FieldErrors) Get(field string) (FieldError, bool) {
+	for _, e := range es {
+		if e.Field == field {
+			return e, true
+		}
+	}
+	return FieldError{}, false
+}
+
+// Message returns the message of the first error of the field path, "" if it's valid.
+func (es FieldErrors) Message(field string) string {
+	e, _ := es.Get(field)
+	return e.Message
+}
+
+// Map returns the message of the first error of each field path.
+func (es FieldErrors) Map() map[string]string {
+	m := make(map[string]string, len(es))
+	for _, e := range es {
+		if _, ok := m[e.Field]; !ok {
+			m[e.Field] = e.Message
+		}
+	}
+	return m
+}
+
+// prefix returns the errors with the field paths prefixed by p, e.g. "[0]" or "user".
+func (es FieldErrors) prefix(p string) FieldErrors {
+	out := make(FieldErrors, 0, len(es))
+	for _, e := range es {
+		switch {
+		case e.Field == "":
+			e.Field = p
+		case strings.HasPrefix(e.Field, "["):
+			e.Field = p + e.Field
+		default:
+			e.Field = p + "." + e.Field
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// AsFieldErrors returns the FieldErrors of err, if it's one.
+func AsFieldErrors(err error) (FieldErrors, bool) {
+	es, ok := err.(FieldErrors)
+	return es, ok
+}
+
+// newFieldErrors converts the errors of the validation of a value of type root.
+func newFieldErrors(root reflect.Type, errs validator.ValidationErrors, trans ut.Translator) FieldErrors {
+	es := make(FieldErrors, 0, len(errs))
+	for _, fe := range errs {
+		e := FieldError{
+			Field: jsonPath(root, fe.StructNamespace()),
+			Tag:   fe.Tag(),
+			Param: fe.Param(),
+		}
+		if trans != nil {
+			e.Message = fe.Translate(trans)
+		} else if err, ok := fe.(error); ok {
+			e.Message = err.Error()
+		}
+		es = append(es, e)
+	}
+	return es
+}
+
+// jsonPath converts a namespace of Go field names, e.g. "User.Profile.Emails[0]",
+// to the json names of the fields, e.g. "profile.emails[0]". The first element,
+// the name of the root type, is dropped as are embedded structs, like encoding/json.
+// The namespace of an unnamed root type, e.g. built from Rules, has no such element.
+func jsonPath(root reflect.Type, namespace string) string {
+	segments := strings.Split(namespace, ".")
+	if t := indirectType(root); t != nil && t.Name() != "" {
+		segments = segments[1:]
+	}
+	t := root
+	var path []string
+	for _, seg := range segments {
+		name, index := seg, ""
+		if i := strings.IndexByte(seg, '['); i >= 0 {
+			name, index = seg[:i], seg[i:]
+		}
+		t = indirectType(t)
+		if t == nil || t.Kind() != reflect.Struct {
+			path = append(path, seg)
+			t = nil
+			continue
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			path = append(path, seg)
+			t = nil
+			continue
+		}
+		t = f.Type
+		for n := strings.Count(index, "["); n > 0; n-- {
+			if t = indirectType(t); t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+				t = t.Elem()
+			}
+		}
+		jsonName := jsonFieldName(f)
+		if jsonName == "" && f.Anonymous && index == "" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+		path = append(path, jsonName+index)
+	}
+	return strings.Join(path, ".")
+}
+
+// jsonFieldName returns the name of the field in the json tag, "" if there's none.
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,105 @@
+package validator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/kataras/iris/v12"
+)
+
+// DefaultLocale is the first locale of the default Config.Locales, the locale of
+// the messages when none of the requested ones is supported.
+const DefaultLocale = "zh"
+
+// localeAliases maps the language tags clients send to the registered locales.
+var localeAliases = map[string]string{
+	"zh_cn":   "zh",
+	"zh_sg":   "zh",
+	"zh_hans": "zh",
+	"zh_tw":   "zh_Hant",
+	"zh_hk":   "zh_Hant",
+	"zh_mo":   "zh_Hant",
+	"zh_hant": "zh_Hant",
+}
+
+// normalizeLocale returns the candidates of a language tag, most specific first,
+// e.g. "en-US" gives "en_US", "en" and "zh-Hant-TW" gives "zh_Hant", "zh".
+func normalizeLocale(locale string) []string {
+	locale = strings.TrimSpace(strings.Replace(locale, "-", "_", -1))
+	if locale == "" || locale == "*" {
+		return nil
+	}
+	parts := strings.Split(locale, "_")
+	var list []string
+	for i := len(parts); i > 0; i-- {
+		l := strings.Join(parts[:i], "_")
+		if alias, ok := localeAliases[strings.ToLower(l)]; ok {
+			l = alias
+		} else if i > 1 {
+			// language_REGION as registered by the locales package.
+			l = strings.ToLower(parts[0]) + "_" + strings.Join(parts[1:i], "_")
+		} else {
+			l = strings.ToLower(l)
+		}
+		list = append(list, l)
+	}
+	return list
+}
+
+// translator returns the translator of the first supported locale, the one of the
+// first Config.Locales otherwise, nil when translation is disabled.
+func (e *engine) translator(locales ...string) ut.Translator {
+	if e.uni == nil {
+		return e.trans
+	}
+	for _, locale := range locales {
+		for _, l := range normalizeLocale(locale) {
+			if t, ok := e.uni.GetTranslator(l); ok {
+				return t
+			}
+		}
+	}
+	return e.trans
+}
+
+// Locales returns the languages of the Accept-Language header of the request,
+// ordered by preference, to be passed to ValidateStructLocale.
+func Locales(ctx iris.Context) []string {
+	return ParseAcceptLanguage(ctx.GetHeader("Accept-Language"))
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header ordered by their q value.
+func ParseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	list := make([]string, 0, len(langs))
+	for _, l := range langs {
+		list = append(list, l.tag)
+	}
+	return list
+}
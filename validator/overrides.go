@@ -0,0 +1,425 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultWatchInterval is the interval of WatchRulesFile when none is given.
+const DefaultWatchInterval = 5 * time.Second
+
+// RuleOverrides are the rules per registered type name and field name,
+// merged with the rules of the tags, see MergeRules. As YAML:
+//
+//	User:
+//	  Nickname: "max=30"        # required,min=2,max=20 becomes required,min=2,max=30
+//	  Email: "-required"        # removes required
+//	  Phone: "=omitempty,cn_mobile" # replaces the rules of the tag
+type RuleOverrides map[string]map[string]string
+
+// MergeRules merges the rules of override into those of a tag: a rule replaces
+// the rule of the same name or is appended, "-name" removes a rule.
+// An override starting with "=" replaces the whole tag, as does any override
+// when either of them uses dive, whose rules depend on their position.
+func MergeRules(tag, override string) string {
+	override = strings.TrimSpace(override)
+	if strings.HasPrefix(override, "=") {
+		return strings.TrimPrefix(override, "=")
+	}
+	if hasRule(tag, "dive") || hasRule(override, "dive") {
+		return override
+	}
+	var rules []string
+	if tag != "" {
+		rules = strings.Split(tag, ",")
+	}
+	for _, o := range strings.Split(override, ",") {
+		if o = strings.TrimSpace(o); o == "" {
+			continue
+		}
+		remove := strings.HasPrefix(o, "-")
+		name := ruleName(strings.TrimPrefix(o, "-"))
+		replaced := false
+		for i := 0; i < len(rules); i++ {
+			if ruleName(rules[i]) != name {
+				continue
+			}
+			if remove {
+				rules = append(rules[:i], rules[i+1:]...)
+				i--
+			} else if !replaced {
+				rules[i] = o
+				replaced = true
+			}
+		}
+		if !remove && !replaced {
+			rules = append(rules, o)
+		}
+	}
+	return strings.Join(rules, ",")
+}
+
+func ruleName(rule string) string {
+	if i := strings.IndexByte(rule, '='); i >= 0 {
+		rule = rule[:i]
+	}
+	return strings.TrimSpace(rule)
+}
+
+func hasRule(tag, name string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if ruleName(r) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleOverride is the validation of the overridden fields of a type.
+type ruleOverride struct {
+	// fields are the names of the overridden fields, excepted from the validation of the type.
+	fields []string
+	// synth has the exported fields of the type, only the overridden ones have rules.
+	synth reflect.Type
+	// index are the indexes in the type of the fields of synth.
+	index []int
+}
+
+// overrideState holds the registered types and the loaded overrides.
+type overrideState struct {
+	types     map[string]reflect.Type
+	overrides RuleOverrides
+	effective map[reflect.Type]*ruleOverride
+}
+
+// RegisterTypes registers the struct types of the samples by name, so their
+// rules can be overridden and dumped.
+func (v *defaultValidator) RegisterTypes(samples ...interface{}) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	types := make(map[string]reflect.Type, len(v.state.types)+len(samples))
+	for name, t := range v.state.types {
+		types[name] = t
+	}
+	for _, s := range samples {
+		t := indirectType(reflect.TypeOf(s))
+		if t == nil || t.Kind() != reflect.Struct || t.Name() == "" {
+			return fmt.Errorf("%w: %T", ErrNotValidatable, s)
+		}
+		types[t.Name()] = t
+	}
+	previous := v.state.types
+	v.state.types = types
+	if err := v.compileOverrides(v.state.overrides); err != nil {
+		v.state.types = previous
+		return err
+	}
+	return nil
+}
+
+// ReadRules reads overrides from r, YAML or JSON, see RuleOverrides.
+func ReadRules(r io.Reader) (RuleOverrides, error) {
+	var overrides RuleOverrides
+	if err := yaml.NewDecoder(r).Decode(&overrides); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("validator: invalid rules: %w", err)
+	}
+	return overrides, nil
+}
+
+// WriteRules writes rules as YAML, sorted by type and field.
+func WriteRules(w io.Writer, rules RuleOverrides) error {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, err := yaml.Marshal(map[string]map[string]string{name: rules[name]})
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadRules replaces the overrides by those read from r, YAML or JSON, see RuleOverrides.
+func (v *defaultValidator) LoadRules(r io.Reader) error {
+	overrides, err := ReadRules(r)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.compileOverrides(overrides)
+}
+
+// LoadRulesFile is LoadRules reading the file at path.
+func (v *defaultValidator) LoadRulesFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return v.LoadRules(f)
+}
+
+// WatchRulesFile loads the file at path and reloads it every time its
+// modification time changes, checked every interval, DefaultWatchInterval
+// when it's 0. The errors of the reloads are passed to onError, if not nil,
+// and the previous rules are kept. stop ends the watch, no reload happens
+// once it returns.
+func (v *defaultValidator) WatchRulesFile(path string, interval time.Duration, onError func(error)) (stop func(), err error) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.LoadRulesFile(path); err != nil {
+		return nil, err
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		modTime, size := fi.ModTime(), fi.Size()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			if fi.ModTime().Equal(modTime) && fi.Size() == size {
+				continue
+			}
+			modTime, size = fi.ModTime(), fi.Size()
+			if err = v.LoadRulesFile(path); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}, nil
+}
+
+// EffectiveRules returns the rules of the fields of each registered type,
+// the overrides merged with the tags.
+func (v *defaultValidator) EffectiveRules() RuleOverrides {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	rules := make(RuleOverrides, len(v.state.types))
+	for name, t := range v.state.types {
+		fields := make(map[string]string)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			tag := f.Tag.Get(v.config.TagName)
+			if o, ok := v.state.overrides[name][f.Name]; ok {
+				tag = MergeRules(tag, o)
+			}
+			if tag != "" {
+				fields[f.Name] = tag
+			}
+		}
+		rules[name] = fields
+	}
+	return rules
+}
+
+// DumpRules writes the EffectiveRules with WriteRules.
+func (v *defaultValidator) DumpRules(w io.Writer) error {
+	return WriteRules(w, v.EffectiveRules())
+}
+
+// compileOverrides checks the overrides against the registered types and
+// builds their validation, it must be called with v.mu locked.
+func (v *defaultValidator) compileOverrides(overrides RuleOverrides) error {
+	effective := make(map[reflect.Type]*ruleOverride)
+	for name, fields := range overrides {
+		t, ok := v.state.types[name]
+		if !ok {
+			// Kept for a type registered later.
+			continue
+		}
+		o := &ruleOverride{}
+		var synth []reflect.StructField
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			tag := "-"
+			if rule, ok := fields[f.Name]; ok {
+				tag = MergeRules(f.Tag.Get(v.config.TagName), rule)
+				o.fields = append(o.fields, f.Name)
+			}
+			synth = append(synth, reflect.StructField{
+				Name:      f.Name,
+				Type:      f.Type,
+				Anonymous: f.Anonymous,
+				Tag: reflect.StructTag(fmt.Sprintf(`json:%q %s:%q %s:%q`,
+					f.Tag.Get("json"), LabelTag, f.Tag.Get(LabelTag), v.config.TagName, tag)),
+			})
+			o.index = append(o.index, i)
+		}
+		for field := range fields {
+			if f, ok := t.FieldByName(field); !ok || f.PkgPath != "" || len(f.Index) > 1 {
+				return fmt.Errorf("validator: invalid rules: %s has no field %s", name, field)
+			}
+		}
+		if len(o.fields) == 0 {
+			continue
+		}
+		st, err := structOf(synth)
+		if err == nil {
+			err = checkRules(v.engine.validate, st)
+		}
+		if err != nil {
+			return fmt.Errorf("validator: invalid rules of %s: %v", name, err)
+		}
+		o.synth = st
+		effective[t] = o
+	}
+	// The engine validates the nested structs itself, without the overrides.
+	for name, t := range v.state.types {
+		if path, nested, ok := v.nestedOverridden(t, effective, name, map[reflect.Type]bool{t: true}); ok {
+			return fmt.Errorf("validator: invalid rules: the overrides of %s do not apply to the nested field %s, "+
+				"only to %s validated directly or as the elements of a slice", nested.Name(), path, nested.Name())
+		}
+	}
+	v.state.overrides = overrides
+	v.state.effective = effective
+	return nil
+}
+
+// nestedOverridden returns the path, e.g. "Order.Items[].Address", of the
+// first validated field of t whose struct type has overrides, at any depth.
+func (v *defaultValidator) nestedOverridden(t reflect.Type, effective map[reflect.Type]*ruleOverride, path string, seen map[reflect.Type]bool) (string, reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if (f.PkgPath != "" && !f.Anonymous) || f.Tag.Get(v.config.TagName) == "-" {
+			continue
+		}
+		ft, p := f.Type, path+"."+f.Name
+	elem:
+		for {
+			switch ft.Kind() {
+			case reflect.Ptr:
+				ft = ft.Elem()
+			case reflect.Slice, reflect.Array, reflect.Map:
+				ft, p = ft.Elem(), p+"[]"
+			default:
+				break elem
+			}
+		}
+		if ft.Kind() != reflect.Struct || seen[ft] {
+			continue
+		}
+		if _, ok := effective[ft]; ok {
+			return p, ft, true
+		}
+		seen[ft] = true
+		if p, nested, ok := v.nestedOverridden(ft, effective, p, seen); ok {
+			return p, nested, true
+		}
+	}
+	return "", nil, false
+}
+
+// structOf is reflect.StructOf returning its panics as errors.
+func structOf(fields []reflect.StructField) (t reflect.Type, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return reflect.StructOf(fields), nil
+}
+
+// checkRules validates a zero value of t, the engine panics on the unknown rules.
+func checkRules(validate *validator.Validate, t reflect.Type) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	_ = validate.Struct(reflect.New(t).Interface())
+	return nil
+}
+
+// validateOverridden validates obj, of a type with overrides, with the
+// overridden fields excepted and then those fields with the merged rules.
+func validateOverridden(ctx context.Context, validate *validator.Validate, obj interface{}, o *ruleOverride) (validator.ValidationErrors, validator.ValidationErrors, error) {
+	var errs, overridden validator.ValidationErrors
+	if err := validate.StructExceptCtx(ctx, obj, o.fields...); err != nil {
+		var ok bool
+		if errs, ok = err.(validator.ValidationErrors); !ok {
+			return nil, nil, err
+		}
+	}
+	src := indirect(reflect.ValueOf(obj))
+	synth := reflect.New(o.synth).Elem()
+	for i, idx := range o.index {
+		synth.Field(i).Set(src.Field(idx))
+	}
+	if err := validate.StructCtx(ctx, synth.Addr().Interface()); err != nil {
+		var ok bool
+		if overridden, ok = err.(validator.ValidationErrors); !ok {
+			return nil, nil, err
+		}
+	}
+	return errs, overridden, nil
+}
+
+// sortFieldErrors sorts the errors in the order of the fields of t, the errors
+// of the fields of embedded structs last.
+func sortFieldErrors(es FieldErrors, t reflect.Type) {
+	order := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonFieldName(f)
+		if name == "" {
+			name = f.Name
+		}
+		order[name] = i
+	}
+	key := func(e FieldError) int {
+		name := e.Field
+		if i := strings.IndexAny(name, ".["); i >= 0 {
+			name = name[:i]
+		}
+		if n, ok := order[name]; ok {
+			return n
+		}
+		return t.NumField()
+	}
+	sort.SliceStable(es, func(i, j int) bool { return key(es[i]) < key(es[j]) })
+}
@@ -0,0 +1,280 @@
+package validator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type account struct {
+	Nickname string `valid:"required,min=2,max=20"`
+	Email    string `valid:"required,email"`
+	Phone    string
+	Tags     []string `valid:"dive,required"`
+	internal string
+}
+
+type address struct {
+	City string `valid:"required"`
+}
+
+type shipment struct {
+	Addresses []*address `valid:"dive"`
+}
+
+func TestMergeRules(t *testing.T) {
+	tests := []struct {
+		tag, override, want string
+	}{
+		{"required,min=2,max=20", "max=30", "required,min=2,max=30"},
+		{"required,min=2", "max=30", "required,min=2,max=30"},
+		{"required,email", "-required", "email"},
+		{"required,email", "-required,-email", ""},
+		{"required,email", "-nope", "required,email"},
+		{"required,email", "=omitempty,e164", "omitempty,e164"},
+		{"required", "=", ""},
+		{"", "required, min=1 ,", "required,min=1"},
+		{"required,min=2", "", "required,min=2"},
+		{"dive,required", "max=3", "max=3"},
+		{"required", "dive,min=1", "dive,min=1"},
+		{"required,oneof=a b", "oneof=c", "required,oneof=c"},
+	}
+	for _, tt := range tests {
+		if got := MergeRules(tt.tag, tt.override); got != tt.want {
+			t.Errorf("MergeRules(%q, %q): expected %q but got %q", tt.tag, tt.override, tt.want, got)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	v := NewValidator()
+	if err := v.RegisterTypes(account{}); err != nil {
+		t.Fatal(err)
+	}
+	obj := &account{Nickname: strings.Repeat("n", 25), Tags: []string{"a"}}
+	if got := fields(t, v.ValidateStruct(obj)); !reflect.DeepEqual(got, []string{"Email", "Nickname"}) {
+		t.Fatalf("expected Email and Nickname to fail but got %v", got)
+	}
+
+	err := v.LoadRules(strings.NewReader(`
+account:
+  Nickname: "max=30"
+  Email: "=omitempty,email"
+  Phone: "=required,numeric"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fields(t, v.ValidateStruct(obj)); !reflect.DeepEqual(got, []string{"Phone"}) {
+		t.Fatalf("expected Phone to fail but got %v", got)
+	}
+	obj.Phone = "123"
+	if err = v.ValidateStruct(obj); err != nil {
+		t.Fatal(err)
+	}
+	// The rules left alone still apply, as do the overrides to the elements of a slice.
+	if got := fields(t, v.ValidateStruct([]account{*obj, {Phone: "x", Tags: []string{""}}})); !reflect.DeepEqual(got, []string{"[1].Nickname", "[1].Phone", "[1].Tags[0]"}) {
+		t.Fatalf("expected the rules of the second element to fail but got %v", got)
+	}
+
+	// A JSON document, replacing the overrides.
+	if err = v.LoadRules(strings.NewReader(`{"account":{"Nickname":"max=10"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := fields(t, v.ValidateStruct(obj)); !reflect.DeepEqual(got, []string{"Email", "Nickname"}) {
+		t.Fatalf("expected Email and Nickname to fail but got %v", got)
+	}
+	// An empty document removes them.
+	if err = v.LoadRules(strings.NewReader("")); err != nil {
+		t.Fatal(err)
+	}
+	obj.Nickname, obj.Email = "nick", "a@b.c"
+	if err = v.ValidateStruct(obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	v := NewValidator()
+	if err := v.RegisterTypes(account{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.LoadRules(strings.NewReader("account:\n  Email: \"=omitempty,email\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range []string{
+		"account:\n  Nope: required\n",
+		"account:\n  internal: required\n",
+		"account: [",
+		"account:\n  Nickname: \"nope\"\n",
+	} {
+		if err := v.LoadRules(strings.NewReader(doc)); err == nil {
+			t.Errorf("%q: expected an error", doc)
+		}
+	}
+	// The previous rules are kept.
+	if err := v.ValidateStruct(&account{Nickname: "nick"}); err != nil {
+		t.Fatalf("expected the previous rules to be kept but got %v", err)
+	}
+	if err := v.RegisterTypes("not a struct"); err == nil {
+		t.Fatal("expected RegisterTypes to refuse a string")
+	}
+}
+
+func TestLoadRulesTypeRegisteredLater(t *testing.T) {
+	v := NewValidator()
+	if err := v.LoadRules(strings.NewReader("account:\n  Email: \"=omitempty,email\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	obj := &account{Nickname: "nick"}
+	if got := fields(t, v.ValidateStruct(obj)); !reflect.DeepEqual(got, []string{"Email"}) {
+		t.Fatalf("expected Email to fail before the registration but got %v", got)
+	}
+	if err := v.RegisterTypes(&account{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.ValidateStruct(obj); err != nil {
+		t.Fatalf("expected the overrides after the registration but got %v", err)
+	}
+}
+
+func TestLoadRulesNested(t *testing.T) {
+	v := NewValidator()
+	if err := v.RegisterTypes(address{}, shipment{}); err != nil {
+		t.Fatal(err)
+	}
+	err := v.LoadRules(strings.NewReader("address:\n  City: \"-required\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "shipment.Addresses[]") {
+		t.Fatalf("expected the nested field to be reported but got %v", err)
+	}
+	// The overrides of a type not used by another registered type are fine.
+	if err = v.LoadRules(strings.NewReader("shipment:\n  Addresses: \"max=1\"\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same when the outer type is registered after the overrides.
+	v = NewValidator()
+	if err = v.RegisterTypes(address{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.LoadRules(strings.NewReader("address:\n  City: \"-required\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.RegisterTypes(shipment{}); err == nil {
+		t.Fatal("expected the registration of shipment to report the nested field")
+	}
+	if rules := v.EffectiveRules(); rules["shipment"] != nil || rules["address"]["City"] != "" {
+		t.Fatalf("expected the failed registration to be undone but got %v", rules)
+	}
+}
+
+func TestEffectiveRules(t *testing.T) {
+	v := NewValidator()
+	if err := v.RegisterTypes(account{}, address{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.LoadRules(strings.NewReader("account:\n  Nickname: \"max=30\"\n  Email: \"-required,-email\"\n  Phone: numeric\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := RuleOverrides{
+		"account": {"Nickname": "required,min=2,max=30", "Phone": "numeric", "Tags": "dive,required"},
+		"address": {"City": "required"},
+	}
+	if got := v.EffectiveRules(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v but got %v", want, got)
+	}
+
+	var b bytes.Buffer
+	if err := v.DumpRules(&b); err != nil {
+		t.Fatal(err)
+	}
+	dumped, err := ReadRules(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dumped, want) {
+		t.Fatalf("expected the dump to read back as %v but got %v", want, dumped)
+	}
+	if i, j := strings.Index(b.String(), "account:"), strings.Index(b.String(), "address:"); i > j {
+		t.Fatalf("expected the types sorted by name in\n%s", b.String())
+	}
+}
+
+func TestWatchRulesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules.yaml")
+	write := func(doc string) {
+		if err := ioutil.WriteFile(path, []byte(doc), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v := NewValidator()
+	if err = v.RegisterTypes(account{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.WatchRulesFile(filepath.Join(dir, "missing.yaml"), time.Millisecond, nil); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+	write("account:\n  Nope: required\n")
+	if _, err = v.WatchRulesFile(path, time.Millisecond, nil); err == nil {
+		t.Fatal("expected an error for invalid rules")
+	}
+
+	write("account:\n  Email: \"-required\"\n")
+	var mu sync.Mutex
+	var errs []error
+	stop, err := v.WatchRulesFile(path, 5*time.Millisecond, func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	emailRules := func() string { return v.EffectiveRules()["account"]["Email"] }
+	if got := emailRules(); got != "email" {
+		t.Fatalf("expected the rules of the file but got %q", got)
+	}
+
+	// eventually waits for cond, the modification times may be coarse.
+	eventually := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+	write("account:\n  Email: \"=omitempty,email\"\n")
+	eventually("the reload", func() bool { return emailRules() == "omitempty,email" })
+
+	write("account:\n  Email: \"-required\"\n  Nope: required\n")
+	eventually("the reload error", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	})
+	if got := emailRules(); got != "omitempty,email" {
+		t.Fatalf("expected the previous rules to be kept but got %q", got)
+	}
+
+	stop()
+	stop()
+	write("account: {}\n")
+	time.Sleep(50 * time.Millisecond)
+	if got := emailRules(); got != "omitempty,email" {
+		t.Fatalf("expected no reload after stop but got %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Rules are the rules of the keys of a map or a JSON object: either the rules
+// of the tag, e.g. "required,min=3", or the Rules of a nested object.
+//
+//	Rules{
+//		"name":    "required,username",
+//		"age":     "omitempty,min=18",
+//		"profile": Rules{"email": "required,email"},
+//	}
+type Rules map[string]interface{}
+
+// keyTag holds the key of the fields of the structs built from Rules,
+// it's the name of the field in the messages.
+const keyTag = "vkey"
+
+// ValidateMap validates the values of data with the rules of the same keys,
+// keys without rules are ignored. The fields of the errors are the keys,
+// e.g. "profile.email".
+func (v *defaultValidator) ValidateMap(data map[string]interface{}, rules Rules, locales ...string) error {
+	value, err := v.ruleStruct(data, rules)
+	if err != nil {
+		return err
+	}
+	return v.validateValue(context.Background(), value.Addr().Interface(), true, locales)
+}
+
+// ValidateJSON validates a JSON object, or each object of a JSON array with
+// the fields of the errors prefixed by "[i]", with the rules like ValidateMap.
+func (v *defaultValidator) ValidateJSON(data []byte, rules Rules, locales ...string) error {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("validator: invalid JSON: %w", err)
+	}
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		return v.ValidateMap(doc, rules, locales...)
+	case []interface{}:
+		var es FieldErrors
+		for i, elem := range doc {
+			m, ok := elem.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%w: JSON array element %d is not an object", ErrNotValidatable, i)
+			}
+			err := v.ValidateMap(m, rules, locales...)
+			if err == nil {
+				continue
+			}
+			errs, ok := err.(FieldErrors)
+			if !ok {
+				return err
+			}
+			es = append(es, errs.prefix("["+strconv.Itoa(i)+"]")...)
+		}
+		if len(es) > 0 {
+			return es
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: JSON value is not an object or an array", ErrNotValidatable)
+}
+
+// ruleStruct builds a struct with a field per rule, tagged with the rule,
+// holding the value of the key in data.
+func (v *defaultValidator) ruleStruct(data map[string]interface{}, rules Rules) (reflect.Value, error) {
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]reflect.StructField, 0, len(keys))
+	values := make([]reflect.Value, 0, len(keys))
+	for i, k := range keys {
+		tag := fmt.Sprintf(`json:%s %s:%s`, strconv.Quote(k), keyTag, strconv.Quote(k))
+		switch rule := rules[k].(type) {
+		case string:
+			fields = append(fields, reflect.StructField{
+				Name: "F" + strconv.Itoa(i),
+				Type: reflect.TypeOf((*interface{})(nil)).Elem(),
+				Tag:  reflect.StructTag(tag + " " + v.config.TagName + ":" + strconv.Quote(rule)),
+			})
+			values = append(values, reflect.ValueOf(data[k]))
+		case Rules, map[string]interface{}:
+			nested, _ := data[k].(map[string]interface{})
+			nv, err := v.ruleStruct(nested, toRules(rule))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			fields = append(fields, reflect.StructField{
+				Name: "F" + strconv.Itoa(i),
+				Type: nv.Type(),
+				Tag:  reflect.StructTag(tag),
+			})
+			values = append(values, nv)
+		default:
+			return reflect.Value{}, fmt.Errorf("validator: invalid rule of %q: %T", k, rule)
+		}
+	}
+
+	value := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, fv := range values {
+		if fv.IsValid() {
+			value.Field(i).Set(fv)
+		}
+	}
+	return value, nil
+}
+
+func toRules(rule interface{}) Rules {
+	if r, ok := rule.(Rules); ok {
+		return r
+	}
+	return Rules(rule.(map[string]interface{}))
+}
@@ -0,0 +1,151 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var signupRules = Rules{
+	"name":    "required,min=3",
+	"age":     "omitempty,min=18",
+	"profile": Rules{"email": "required,email"},
+}
+
+// fields returns the sorted fields of the FieldErrors err, nil when err is nil.
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	es, ok := AsFieldErrors(err)
+	if !ok {
+		t.Fatalf("expected FieldErrors but got %v", err)
+	}
+	var list []string
+	for _, e := range es {
+		list = append(list, e.Field)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestValidateMap(t *testing.T) {
+	v := NewValidator()
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		fields []string
+	}{
+		{"valid", map[string]interface{}{"name": "alice", "age": 20, "profile": map[string]interface{}{"email": "a@b.c"}}, nil},
+		{"optional", map[string]interface{}{"name": "alice", "profile": map[string]interface{}{"email": "a@b.c"}}, nil},
+		{"extra keys", map[string]interface{}{"name": "alice", "other": "", "profile": map[string]interface{}{"email": "a@b.c"}}, nil},
+		{"short", map[string]interface{}{"name": "al", "age": 10, "profile": map[string]interface{}{"email": "a@b.c"}}, []string{"age", "name"}},
+		{"nested", map[string]interface{}{"name": "alice", "profile": map[string]interface{}{"email": "nope"}}, []string{"profile.email"}},
+		{"missing nested", map[string]interface{}{"name": "alice"}, []string{"profile.email"}},
+		{"nested not an object", map[string]interface{}{"name": "alice", "profile": "a@b.c"}, []string{"profile.email"}},
+		{"empty", map[string]interface{}{}, []string{"name", "profile.email"}},
+		{"nil", nil, []string{"name", "profile.email"}},
+	}
+	for _, tt := range tests {
+		if got := fields(t, v.ValidateMap(tt.data, signupRules)); !reflect.DeepEqual(got, tt.fields) {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.fields, got)
+		}
+	}
+
+	err := v.ValidateMap(map[string]interface{}{"profile": map[string]interface{}{"email": "nope"}}, signupRules, "en")
+	if msg := message(t, err, "profile.email"); msg == "" || msg == "profile.email" {
+		t.Fatalf("expected a translated message but got %q", msg)
+	}
+	// Plain maps are accepted for nested rules.
+	err = v.ValidateMap(map[string]interface{}{}, Rules{"profile": map[string]interface{}{"email": "required"}})
+	if got := fields(t, err); !reflect.DeepEqual(got, []string{"profile.email"}) {
+		t.Fatalf("expected profile.email but got %v", got)
+	}
+	if err = v.ValidateMap(map[string]interface{}{}, Rules{"name": 3}); err == nil || errors.Is(err, ErrNotValidatable) {
+		t.Fatalf("expected an invalid rule error but got %v", err)
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	v := NewValidator()
+	tests := []struct {
+		name   string
+		data   string
+		fields []string
+		err    error
+	}{
+		{name: "object", data: `{"name":"alice","profile":{"email":"a@b.c"}}`},
+		{name: "invalid object", data: `{"name":"al","profile":{"email":"a@b.c"}}`, fields: []string{"name"}},
+		{name: "array", data: `[{"name":"alice","profile":{"email":"a@b.c"}},{"name":"bob","profile":{"email":"b@c.d"}}]`},
+		{name: "invalid elements", data: `[{"name":"alice","profile":{"email":"a@b.c"}},{"name":"bo","profile":{"email":"nope"}},{"name":"carol"}]`,
+			fields: []string{"[1].name", "[1].profile.email", "[2].profile.email"}},
+		{name: "empty array", data: `[]`},
+		{name: "element not an object", data: `[{"name":"alice","profile":{"email":"a@b.c"}},"bob"]`, err: ErrNotValidatable},
+		{name: "string", data: `"alice"`, err: ErrNotValidatable},
+		{name: "null", data: `null`, err: ErrNotValidatable},
+	}
+	for _, tt := range tests {
+		err := v.ValidateJSON([]byte(tt.data), signupRules)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v but got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if got := fields(t, err); !reflect.DeepEqual(got, tt.fields) {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.fields, got)
+		}
+	}
+
+	if err := v.ValidateJSON([]byte(`{"name":`), signupRules); err == nil || errors.Is(err, ErrNotValidatable) {
+		t.Fatalf("expected a JSON syntax error but got %v", err)
+	}
+}
+
+type member struct {
+	Name    string `json:"name" valid:"required"`
+	Profile struct {
+		Email string `json:"email" valid:"omitempty,email"`
+	} `json:"profile"`
+}
+
+func TestValidateStructSlice(t *testing.T) {
+	v := NewValidator()
+	valid := member{Name: "alice"}
+	invalid := member{}
+	invalid.Profile.Email = "nope"
+
+	tests := []struct {
+		name   string
+		obj    interface{}
+		fields []string
+		err    error
+	}{
+		{name: "slice", obj: []member{valid, valid}},
+		{name: "invalid elements", obj: []member{valid, invalid, {}}, fields: []string{"[1].name", "[1].profile.email", "[2].name"}},
+		{name: "pointer to a slice", obj: &[]member{valid, {}}, fields: []string{"[1].name"}},
+		{name: "slice of pointers", obj: []*member{nil, &valid, &invalid}, fields: []string{"[2].name", "[2].profile.email"}},
+		{name: "array", obj: [2]member{{}, valid}, fields: []string{"[0].name"}},
+		{name: "empty slice", obj: []member{}},
+		{name: "nil slice", obj: []member(nil)},
+		{name: "slice of strings", obj: []string{"a"}, err: ErrNotValidatable},
+		{name: "map", obj: map[string]member{"a": valid}, err: ErrNotValidatable},
+		{name: "string", obj: "alice", err: ErrNotValidatable},
+		{name: "nil", obj: nil, err: ErrNotValidatable},
+		{name: "nil pointer", obj: (*member)(nil), err: ErrNotValidatable},
+	}
+	for _, tt := range tests {
+		err := v.ValidateStruct(tt.obj)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v but got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if got := fields(t, err); !reflect.DeepEqual(got, tt.fields) {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.fields, got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// SanitizeTag is the struct tag of the transforms applied to a string field,
+// in order, by Sanitize. The binding package and the Validators with
+// Config.Sanitize apply them before the validation:
+//
+//	Name  string   `sanitize:"trim,collapse" valid:"required,max=20"`
+//	Email string   `sanitize:"trim,halfwidth,lower" valid:"email"`
+//	Tags  []string `sanitize:"trim"`
+//
+// The transforms are applied to string, *string and []string fields, and the
+// nested structs and slices of structs are sanitized recursively.
+const SanitizeTag = "sanitize"
+
+// Sanitizer transforms a string.
+type Sanitizer func(string) string
+
+var (
+	sanitizersMu sync.RWMutex
+	sanitizers   = map[string]Sanitizer{
+		"trim":       strings.TrimSpace,
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"collapse":   collapseSpace,
+		"halfwidth":  width.Fold.String,
+		"strip_html": stripHTML,
+		"nfc":        norm.NFC.String,
+	}
+)
+
+// RegisterSanitizer adds or replaces a transform of the sanitize tag.
+// The transforms trim, lower, upper, collapse (trim and whitespace runs to a space),
+// halfwidth (full-width letters, digits, symbols and spaces to ASCII),
+// strip_html and nfc are predefined.
+func RegisterSanitizer(name string, fn Sanitizer) {
+	sanitizersMu.Lock()
+	sanitizers[name] = fn
+	sanitizersMu.Unlock()
+}
+
+// Sanitize applies the sanitize tags of obj, a pointer to a struct or a slice
+// of structs, see SanitizeTag. The values that can't be set are left as is.
+func Sanitize(obj interface{}) error {
+	if obj == nil {
+		return nil
+	}
+	return sanitizeValue(reflect.ValueOf(obj), make(map[visit]bool))
+}
+
+// visit is a pointer already sanitized, for the cycles.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// sanitizeValue sanitizes the structs of v, with the pointers in visited skipped.
+func sanitizeValue(v reflect.Value, visited map[visit]bool) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		key := visit{v.Pointer(), v.Type()}
+		if v.IsNil() || visited[key] {
+			return nil
+		}
+		visited[key] = true
+		return sanitizeValue(v.Elem(), visited)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return sanitizeValue(v.Elem(), visited)
+	case reflect.Slice, reflect.Array:
+		if kind := indirectType(v.Type().Elem()).Kind(); kind != reflect.Struct && kind != reflect.Slice && kind != reflect.Array {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := sanitizeValue(v.Index(i), visited); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return sanitizeStruct(v, visited)
+	}
+	return nil
+}
+
+func sanitizeStruct(v reflect.Value, visited map[visit]bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		field := v.Field(i)
+		tag := f.Tag.Get(SanitizeTag)
+		if tag == "" || tag == "-" {
+			if err := sanitizeValue(field, visited); err != nil {
+				return err
+			}
+			continue
+		}
+		fns, err := parseSanitizers(tag)
+		if err != nil {
+			return fmt.Errorf("validator: %s.%s: %w", t, f.Name, err)
+		}
+		sanitizeString(field, fns)
+	}
+	return nil
+}
+
+// sanitizeString applies fns to a string, *string or []string.
+func sanitizeString(v reflect.Value, fns []Sanitizer) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			sanitizeString(v.Elem(), fns)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			sanitizeString(v.Index(i), fns)
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return
+		}
+		s := v.String()
+		for _, fn := range fns {
+			s = fn(s)
+		}
+		v.SetString(s)
+	}
+}
+
+func parseSanitizers(tag string) ([]Sanitizer, error) {
+	sanitizersMu.RLock()
+	defer sanitizersMu.RUnlock()
+	var fns []Sanitizer
+	for _, name := range strings.Split(tag, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		fn, ok := sanitizers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sanitizer %q", name)
+		}
+		fns = append(fns, fn)
+	}
+	return fns, nil
+}
+
+// collapseSpace replaces the runs of whitespace by a space and trims s.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// stripHTML returns the text of s without the tags, comments, scripts and
+// styles, the entities unescaped. It's repeated until the text stops changing,
+// so that escaped markup like "&lt;script&gt;" is removed too instead of
+// becoming live.
+func stripHTML(s string) string {
+	for {
+		stripped := stripHTMLOnce(s)
+		if stripped == s {
+			return s
+		}
+		s = stripped
+	}
+}
+
+func stripHTMLOnce(s string) string {
+	if !strings.ContainsAny(s, "<&") {
+		return s
+	}
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken:
+			if name, _ := z.TagName(); isRawTextTag(name) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); isRawTextTag(name) && skip > 0 {
+				skip--
+			}
+		}
+	}
+}
+
+func isRawTextTag(name []byte) bool {
+	switch string(name) {
+	case "script", "style":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripHTML(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"plain text", "plain text"},
+		{"<b>bold</b> text", "bold text"},
+		{"a<script>alert(1)</script>b", "ab"},
+		{"<style>p{}</style>x<!-- comment -->y", "xy"},
+		{"Tom &amp; Jerry", "Tom & Jerry"},
+		{"&lt;script&gt;alert(1)&lt;/script&gt;", ""},
+		{"&amp;lt;b&amp;gt;x&amp;lt;/b&amp;gt;", "x"},
+		{"1 < 2", "1 < 2"},
+	}
+	for _, tt := range tests {
+		got := stripHTML(tt.in)
+		if got != tt.out {
+			t.Errorf("%q: expected %q but got %q", tt.in, tt.out, got)
+		}
+		if strings.Contains(got, "<script") {
+			t.Errorf("%q: live markup in %q", tt.in, got)
+		}
+	}
+}
+
+type sanitizeAddress struct {
+	City string `sanitize:"trim,collapse"`
+}
+
+type sanitizeUser struct {
+	Name     string   `sanitize:"trim,halfwidth,lower" valid:"required,max=5"`
+	Bio      *string  `sanitize:"strip_html"`
+	Tags     []string `sanitize:"trim,upper"`
+	Address  sanitizeAddress
+	Previous []*sanitizeAddress
+	Next     *sanitizeUser `valid:"-"`
+}
+
+func TestSanitize(t *testing.T) {
+	bio := "<p>hi</p>"
+	u := &sanitizeUser{
+		Name:     "  ＡＤＭＩＮ ",
+		Bio:      &bio,
+		Tags:     []string{" a ", "b"},
+		Address:  sanitizeAddress{City: "  New \t York "},
+		Previous: []*sanitizeAddress{{City: " Paris "}, nil},
+	}
+	u.Next = u
+	if err := Sanitize(u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "admin" || bio != "hi" || u.Tags[0] != "A" || u.Tags[1] != "B" ||
+		u.Address.City != "New York" || u.Previous[0].City != "Paris" {
+		t.Fatalf("unexpected result %+v", u)
+	}
+
+	type unknown struct {
+		Name string `sanitize:"nope"`
+	}
+	if err := Sanitize(&unknown{}); err == nil || !strings.Contains(err.Error(), `unknown sanitizer "nope"`) {
+		t.Fatalf("expected an unknown sanitizer error but got %v", err)
+	}
+}
+
+func TestValidateStructSanitize(t *testing.T) {
+	u := sanitizeUser{Name: " ＡＤＭＩＮ "}
+	if err := NewValidator().ValidateStruct(&u); err == nil {
+		t.Fatal("expected the unsanitized name to be too long")
+	}
+	if u.Name != " ＡＤＭＩＮ " {
+		t.Fatalf("expected the struct to be left as is but got %q", u.Name)
+	}
+	if err := NewValidator(Config{Sanitize: true}).ValidateStruct(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "admin" {
+		t.Fatalf("expected the sanitized name but got %q", u.Name)
+	}
+}
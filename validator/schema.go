@@ -0,0 +1,421 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/griffin702/service/tools"
+)
+
+// JSONSchemaDraft is the $schema of the documents of JSONSchema.
+const JSONSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema, or an OpenAPI 3 schema object, derived from the
+// json and rule tags of a struct.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     interface{}        `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     interface{}        `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	// Nullable is only set for OpenAPI, on pointers.
+	Nullable bool `json:"nullable,omitempty"`
+}
+
+var (
+	schemaPatternsMu sync.RWMutex
+	schemaPatterns   = map[string]string{
+		"alpha":       `^[a-zA-Z]+$`,
+		"alphanum":    `^[a-zA-Z0-9]+$`,
+		"numeric":     `^[-+]?[0-9]+(?:\.[0-9]+)?$`,
+		"number":      `^[0-9]+$`,
+		"lowercase":   `^[^A-Z]*$`,
+		"uppercase":   `^[^a-z]*$`,
+		"cn_idcard":   `^[1-9][0-9]{5}(?:18|19|20)[0-9]{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12][0-9]|3[01])[0-9]{3}[0-9Xx]$`,
+		"cn_uscc":     `^[0-9A-HJ-NPQRTUWXYa-hj-npqrtuwxy]{18}$`,
+		"cn_bankcard": `^[0-9]{12,19}$`,
+		"cn_postcode": postcodePattern,
+		"cn_plate":    platePattern,
+		"username":    `^(?![0-9]+$)[0-9A-Za-z_]{6,30}$`,
+		"nickname":    "^[^\\u0020-\\u002F\\u003A-\\u0040\\u005B-\\u0060\\u00A0-\\u00BF]*$",
+		"cn_email":    `^[0-9a-z][_.0-9a-z-]{0,31}@([0-9a-z][0-9a-z-]{0,30}[0-9a-z]\.){1,4}[a-z]{2,4}$`,
+	}
+	schemaFormats = map[string]string{
+		"email":    "email",
+		"url":      "uri",
+		"uri":      "uri",
+		"uuid":     "uuid",
+		"uuid4":    "uuid",
+		"ipv4":     "ipv4",
+		"ipv6":     "ipv6",
+		"hostname": "hostname",
+		"datetime": "date-time",
+	}
+)
+
+// RegisterSchemaPattern sets the ECMA 262 regular expression of the schemas
+// of the fields with the tag, e.g. for a tag registered with RegisterValidation.
+func RegisterSchemaPattern(tag, pattern string) {
+	schemaPatternsMu.Lock()
+	schemaPatterns[tag] = pattern
+	schemaPatternsMu.Unlock()
+}
+
+func schemaPattern(tag string) (string, bool) {
+	if tag == "cn_mobile" {
+		return mobilePattern(), true
+	}
+	schemaPatternsMu.RLock()
+	defer schemaPatternsMu.RUnlock()
+	p, ok := schemaPatterns[tag]
+	return p, ok
+}
+
+// mobilePattern builds the pattern of cn_mobile from the prefix table.
+func mobilePattern() string {
+	byLen := make(map[int][]string)
+	for _, p := range tools.MobilePrefixes() {
+		byLen[len(p)] = append(byLen[len(p)], p)
+	}
+	lengths := make([]int, 0, len(byLen))
+	for l := range byLen {
+		lengths = append(lengths, l)
+	}
+	sort.Ints(lengths)
+	var alts []string
+	for _, l := range lengths {
+		sort.Strings(byLen[l])
+		alts = append(alts, "(?:"+strings.Join(byLen[l], "|")+")[0-9]{"+strconv.Itoa(11-l)+"}")
+	}
+	return "^(?:" + strings.Join(alts, "|") + ")$"
+}
+
+// schemaBuilder derives the schemas of the types of a Validator.
+type schemaBuilder struct {
+	v        *defaultValidator
+	openAPI  bool
+	visiting map[reflect.Type]bool
+}
+
+// JSONSchema returns the JSON Schema (draft 07) of obj, a struct or a pointer to a struct.
+func (v *defaultValidator) JSONSchema(obj interface{}) (*Schema, error) {
+	s, err := v.schema(obj, false)
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = JSONSchemaDraft
+	return s, nil
+}
+
+// OpenAPISchema returns the OpenAPI 3.0 schema object of obj, a struct or a pointer to a struct.
+func (v *defaultValidator) OpenAPISchema(obj interface{}) (*Schema, error) {
+	return v.schema(obj, true)
+}
+
+func (v *defaultValidator) schema(obj interface{}, openAPI bool) (*Schema, error) {
+	if obj == nil {
+		return nil, fmt.Errorf("%w: nil", ErrNotValidatable)
+	}
+	t := indirectType(reflect.TypeOf(obj))
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T", ErrNotValidatable, obj)
+	}
+	b := &schemaBuilder{v: v, openAPI: openAPI, visiting: make(map[reflect.Type]bool)}
+	return b.typeSchema(t, ""), nil
+}
+
+// typeSchema returns the schema of t with the rules of tag.
+func (b *schemaBuilder) typeSchema(t reflect.Type, tag string) *Schema {
+	s := &Schema{}
+	if t.Kind() == reflect.Ptr {
+		s = b.typeSchema(t.Elem(), tag)
+		s.Nullable = b.openAPI
+		return s
+	}
+
+	rules, dive := splitDive(tag)
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		s.Type, s.Format = "string", "date-time"
+	case t.Kind() == reflect.Struct:
+		b.structSchema(s, t)
+	case t.Kind() == reflect.String:
+		s.Type = "string"
+	case t.Kind() == reflect.Bool:
+		s.Type = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s.Type = "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s.Type = "number"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		s.Type, s.Format = "string", "byte"
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s.Type = "array"
+		s.Items = b.typeSchema(t.Elem(), dive)
+	case t.Kind() == reflect.Map:
+		s.Type = "object"
+		s.AdditionalProperties = b.typeSchema(t.Elem(), dive)
+	}
+	b.applyRules(s, t, rules)
+	return s
+}
+
+// structSchema sets the properties of the exported fields of t.
+func (b *schemaBuilder) structSchema(s *Schema, t reflect.Type) {
+	s.Type = "object"
+	if b.visiting[t] {
+		return
+	}
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	s.Properties = make(map[string]*Schema)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		jsonTag := f.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name := jsonFieldName(f)
+		tag := b.v.fieldRules(t, f)
+		if tag == "-" {
+			tag = ""
+		}
+		ft := indirectType(f.Type)
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded := b.typeSchema(ft, "")
+			for k, p := range embedded.Properties {
+				s.Properties[k] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		p := b.typeSchema(f.Type, tag)
+		p.Title = f.Tag.Get(LabelTag)
+		s.Properties[name] = p
+		if rules, _ := splitDive(tag); hasRule(rules, "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// fieldRules returns the rules of the field of t, the overrides merged.
+func (v *defaultValidator) fieldRules(t reflect.Type, f reflect.StructField) string {
+	tag := f.Tag.Get(v.config.TagName)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if registered, ok := v.state.types[t.Name()]; ok && registered == t {
+		if o, ok := v.state.overrides[t.Name()][f.Name]; ok {
+			tag = MergeRules(tag, o)
+		}
+	}
+	return tag
+}
+
+// splitDive splits the rules of a field and those of its elements.
+func splitDive(tag string) (rules, dive string) {
+	parts := strings.Split(tag, ",")
+	for i, p := range parts {
+		if strings.TrimSpace(p) == "dive" {
+			return strings.Join(parts[:i], ","), strings.Join(parts[i+1:], ",")
+		}
+	}
+	return tag, ""
+}
+
+// applyRules sets the constraints of the rules on s, the unknown rules are ignored.
+func (b *schemaBuilder) applyRules(s *Schema, t reflect.Type, rules string) {
+	b.applyConstraints(s, rules)
+	if hasRule(rules, "required") && s.Type == "string" && (s.MinLength == nil || *s.MinLength < 1) {
+		// required rejects the empty strings.
+		s.MinLength = intPtr(1)
+	}
+	if hasRule(rules, "omitempty") {
+		allowZero(s)
+	}
+}
+
+// allowZero makes s accept the zero value of its type, skipped by omitempty,
+// when its constraints reject it.
+func allowZero(s *Schema) {
+	var zero interface{}
+	switch s.Type {
+	case "string":
+		if s.Pattern == "" && s.Format == "" && s.Enum == nil && (s.MinLength == nil || *s.MinLength == 0) {
+			return
+		}
+		zero = ""
+	case "integer", "number":
+		if s.Enum == nil && s.Minimum == nil && s.Maximum == nil && s.ExclusiveMinimum == nil && s.ExclusiveMaximum == nil {
+			return
+		}
+		zero = 0
+	default:
+		return
+	}
+	c := &Schema{
+		Format:           s.Format,
+		Pattern:          s.Pattern,
+		Enum:             s.Enum,
+		Minimum:          s.Minimum,
+		Maximum:          s.Maximum,
+		ExclusiveMinimum: s.ExclusiveMinimum,
+		ExclusiveMaximum: s.ExclusiveMaximum,
+		MinLength:        s.MinLength,
+		MaxLength:        s.MaxLength,
+	}
+	s.Format, s.Pattern, s.Enum = "", "", nil
+	s.Minimum, s.Maximum, s.ExclusiveMinimum, s.ExclusiveMaximum = nil, nil, nil, nil
+	s.MinLength, s.MaxLength = nil, nil
+	s.AnyOf = []*Schema{{Enum: []interface{}{zero}}, c}
+}
+
+// applyConstraints sets the constraints of the rules but required and omitempty.
+func (b *schemaBuilder) applyConstraints(s *Schema, rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		name, param := ruleName(rule), ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			param = rule[i+1:]
+		}
+		switch name {
+		case "", "required", "omitempty":
+		case "len":
+			b.setBound(s, param, true, true)
+		case "min", "gte":
+			b.setBound(s, param, true, false)
+		case "max", "lte":
+			b.setBound(s, param, false, true)
+		case "gt", "lt":
+			if s.Type != "integer" && s.Type != "number" {
+				n, err := strconv.Atoi(param)
+				if err == nil && name == "gt" {
+					b.setBound(s, strconv.Itoa(n+1), true, false)
+				} else if err == nil {
+					b.setBound(s, strconv.Itoa(n-1), false, true)
+				}
+				continue
+			}
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			if b.openAPI {
+				if name == "gt" {
+					s.Minimum, s.ExclusiveMinimum = &n, true
+				} else {
+					s.Maximum, s.ExclusiveMaximum = &n, true
+				}
+			} else if name == "gt" {
+				s.ExclusiveMinimum = n
+			} else {
+				s.ExclusiveMaximum = n
+			}
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, value))
+			}
+		case "password_level":
+			if p := passwordPolicy(param); p != nil {
+				if p.MinLength > 0 {
+					s.MinLength = intPtr(p.MinLength)
+				}
+				if p.MaxLength > 0 {
+					s.MaxLength = intPtr(p.MaxLength)
+				}
+			}
+		default:
+			if format, ok := schemaFormats[name]; ok {
+				s.Format = format
+			} else if pattern, ok := schemaPattern(name); ok {
+				s.Pattern = pattern
+			}
+		}
+	}
+}
+
+// setBound sets the minimum and/or maximum of the length, the items or the value.
+func (b *schemaBuilder) setBound(s *Schema, param string, min, max bool) {
+	switch s.Type {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		if min {
+			s.Minimum = &n
+		}
+		if max {
+			s.Maximum = &n
+		}
+		return
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return
+	}
+	var lo, hi **int
+	switch s.Type {
+	case "string":
+		lo, hi = &s.MinLength, &s.MaxLength
+	case "array":
+		lo, hi = &s.MinItems, &s.MaxItems
+	case "object":
+		lo, hi = &s.MinProperties, &s.MaxProperties
+	default:
+		return
+	}
+	if min {
+		*lo = intPtr(n)
+	}
+	if max {
+		*hi = intPtr(n)
+	}
+}
+
+func enumValue(typ, value string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,93 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+type schemaAddress struct {
+	City  string `json:"city" valid:"required"`
+	Plate string `json:"plate" valid:"omitempty,cn_plate"`
+}
+
+type schemaUser struct {
+	Name     string            `json:"name" label:"姓名" valid:"required,min=2,max=20"`
+	Code     string            `json:"code" valid:"required"`
+	Email    string            `json:"email" valid:"omitempty,email"`
+	Bio      string            `json:"bio" valid:"omitempty,max=200"`
+	Nickname string            `json:"nickname" valid:"omitempty,min=2"`
+	Role     string            `json:"role" valid:"omitempty,oneof=admin user"`
+	Age      int               `json:"age" valid:"omitempty,gte=18,lte=130"`
+	Score    float64           `json:"score" valid:"gt=0,lt=100"`
+	USCC     string            `json:"uscc" valid:"omitempty,cn_uscc"`
+	Tags     []string          `json:"tags" valid:"max=5,dive,required"`
+	Address  *schemaAddress    `json:"address"`
+	Meta     map[string]string `json:"meta" valid:"omitempty,max=10"`
+	Ignored  string            `json:"-"`
+}
+
+func TestSchemaGolden(t *testing.T) {
+	v := NewValidator()
+	for _, tt := range []struct {
+		golden string
+		schema func(interface{}) (*Schema, error)
+	}{
+		{"schema.golden.json", v.JSONSchema},
+		{"openapi.golden.json", v.OpenAPISchema},
+	} {
+		s, err := tt.schema(&schemaUser{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, '\n')
+		path := filepath.Join("testdata", tt.golden)
+		if *update {
+			if err = ioutil.WriteFile(path, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs, run go test -update:\n%s", path, got)
+		}
+	}
+}
+
+func TestSchemaPatternsMatchChecks(t *testing.T) {
+	tests := []struct {
+		tag   string
+		check func(string) bool
+		value string
+	}{
+		{"cn_plate", IsCNPlate, "京A12345"},
+		{"cn_plate", IsCNPlate, "京a1234b"},
+		{"cn_plate", IsCNPlate, "粤bd12345"},
+		{"cn_uscc", IsCNUSCC, "91350100M000100Y43"},
+		{"cn_uscc", IsCNUSCC, "91350100m000100y43"},
+		{"cn_mobile", IsCNMobile, "19212345678"},
+	}
+	for _, tt := range tests {
+		if !tt.check(tt.value) {
+			t.Fatalf("%s: %q is not valid", tt.tag, tt.value)
+		}
+		pattern, _ := schemaPattern(tt.tag)
+		if !regexp.MustCompile(pattern).MatchString(tt.value) {
+			t.Errorf("%s: the pattern %s rejects %q", tt.tag, pattern, tt.value)
+		}
+	}
+}
@@ -1,58 +1,279 @@
 package validator
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"reflect"
-	"strings"
+	"strconv"
 	"sync"
+	"time"
 
-	"github.com/go-playground/locales/zh"
+	"github.com/go-playground/locales"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
-	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
 )
 
+// ErrNotValidatable is returned when the value to validate has an unsupported type.
+var ErrNotValidatable = errors.New("validator: value is not a struct, a pointer to a struct or a slice of them")
+
+// Validator validates structs with the rules of their "valid" tags,
+// failed rules are returned as FieldErrors.
 type Validator interface {
 	ValidateStruct(obj interface{}, translation ...bool) error
 	Engine(translation ...bool) interface{}
 	RegisterValidation(tag string, fn validator.Func, callValidationEvenIfNull ...bool) error
 }
 
-func NewValidator() Validator {
-	return &defaultValidator{}
+// ExtendedValidator is the Validator returned by NewValidator. Its methods
+// are kept out of Validator so the other implementations of Validator don't
+// have to provide them, check for them with a type assertion when needed.
+type ExtendedValidator interface {
+	Validator
+	// ValidateStructLocale is ValidateStruct translating the errors to the first
+	// supported locale, e.g. the result of Locales(ctx), falling back to the first locale of the Config.
+	ValidateStructLocale(obj interface{}, locales ...string) error
+	// ValidateMap validates the values of data with the rules of the same keys, see Rules.
+	ValidateMap(data map[string]interface{}, rules Rules, locales ...string) error
+	// ValidateJSON validates a JSON object, or each object of a JSON array, with the rules.
+	ValidateJSON(data []byte, rules Rules, locales ...string) error
+	// ValidateStructCtx is ValidateStructLocale passing ctx to the rules registered
+	// with RegisterValidationCtx and RegisterStructValidationCtx. It returns the
+	// error of ctx when it's done before or during the validation.
+	ValidateStructCtx(ctx context.Context, obj interface{}, locales ...string) error
+	// Sanitizes reports whether the validations sanitize the structs first, see Config.Sanitize.
+	Sanitizes() bool
+	// RegisterValidationCtx registers a field level rule receiving the context of ValidateStructCtx.
+	RegisterValidationCtx(tag string, fn validator.FuncCtx, callValidationEvenIfNull ...bool) error
+	// RegisterStructValidation registers a rule of the whole struct for the types,
+	// it reports the failed fields with StructLevel.ReportError.
+	RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{})
+	// RegisterStructValidationCtx is RegisterStructValidation for a rule receiving
+	// the context of ValidateStructCtx, e.g. to query a repository.
+	RegisterStructValidationCtx(fn validator.StructLevelFuncCtx, types ...interface{})
+	// RegisterValidationWithTranslation is RegisterValidation and RegisterTranslation.
+	RegisterValidationWithTranslation(tag string, fn validator.Func, templates map[string]string, callValidationEvenIfNull ...bool) error
+	// RegisterTranslation registers the messages of a tag per locale, e.g. the
+	// tags reported by struct level rules, replacing the existing ones, those of
+	// the built-in tags included. {0} is the name of the field, the value of its
+	// label tag if any, and {1} the param. The locales without template use the
+	// "en" one.
+	RegisterTranslation(tag string, templates map[string]string) error
+
+	// RegisterTypes registers struct types by name for the rule overrides and DumpRules.
+	RegisterTypes(samples ...interface{}) error
+	// LoadRules replaces the rule overrides by those of a YAML or JSON document,
+	// see RuleOverrides. They apply to the registered types validated directly
+	// or as the elements of a slice, not as nested structs: overriding a type
+	// used by a field of a registered type is an error.
+	LoadRules(r io.Reader) error
+	// LoadRulesFile is LoadRules reading a file.
+	LoadRulesFile(path string) error
+	// WatchRulesFile loads a file and reloads it when it changes, until stop is called.
+	WatchRulesFile(path string, interval time.Duration, onError func(error)) (stop func(), err error)
+	// EffectiveRules returns the rules of the fields of the registered types, overrides included.
+	EffectiveRules() RuleOverrides
+	// DumpRules writes the EffectiveRules as YAML.
+	DumpRules(w io.Writer) error
+
+	// JSONSchema returns the JSON Schema of a struct derived from its json, label
+	// and rule tags, overrides included, for the clients to apply the same rules.
+	JSONSchema(obj interface{}) (*Schema, error)
+	// OpenAPISchema is JSONSchema as an OpenAPI 3.0 schema object.
+	OpenAPISchema(obj interface{}) (*Schema, error)
+}
+
+// NewValidator constructs a new Validator with supplied options.
+// It's safe for concurrent use, validations can be registered at any time.
+func NewValidator(cfg ...Config) ExtendedValidator {
+	var c Config
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	if c.TagName == "" {
+		c.TagName = DefaultTagName
+	}
+	if len(c.Locales) == 0 {
+		c.Locales = defaultLocales
+	}
+
+	return &defaultValidator{config: c, engine: newEngine(c)}
 }
 
 type defaultValidator struct {
-	once     sync.Once
+	mu     sync.RWMutex
+	config Config
+	// engine is modified under the write lock and used under the read lock, see register.
+	engine *engine
+	state  overrideState
+}
+
+// engine is a validator.Validate with its translators.
+type engine struct {
 	validate *validator.Validate
-	trans    ut.Translator
+	uni      *ut.UniversalTranslator
+	// locales are the supported locales of the Config, in order.
+	locales []string
+	// trans is the translator of the first locale, nil when translation is disabled.
+	trans ut.Translator
+}
+
+// newEngine returns an engine with the builtins registered.
+func newEngine(c Config) *engine {
+	e := &engine{validate: validator.New()}
+	e.validate.SetTagName(c.TagName)
+	e.validate.RegisterTagNameFunc(displayName)
+	if !c.DisableTranslation {
+		e.initTranslations(c.Locales)
+	}
+	e.registerBuiltins()
+	return e
+}
+
+func (e *engine) initTranslations(tags []string) {
+	var list []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if l := supportedLocale(tag); l != "" && !seen[l] {
+			seen[l] = true
+			list = append(list, l)
+		}
+	}
+	if len(list) == 0 {
+		list = defaultLocales
+	}
+
+	translators := make([]locales.Translator, 0, len(list))
+	for _, l := range list {
+		translators = append(translators, supportedLocales[l].new())
+	}
+	e.uni = ut.New(translators[0], translators...)
+	for _, l := range list {
+		trans, _ := e.uni.GetTranslator(l)
+		_ = supportedLocales[l].register(e.validate, trans)
+	}
+	e.locales = list
+	e.trans, _ = e.uni.GetTranslator(list[0])
 }
 
-// ValidateStruct receives any kind of type, but only performed struct or pointer to struct type.
+// register runs fn on the engine under the write lock. The engine is not
+// safe for registrations during validations, so they wait for the
+// validations in progress, which hold the read lock.
+func (v *defaultValidator) register(fn func(*engine) error) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return fn(v.engine)
+}
+
+// ValidateStruct validates a struct, a pointer to a struct or a slice of them,
+// the fields of the elements of a slice are reported as "[i].field".
+// With Config.Sanitize, the fields of obj are sanitized first, see SanitizeTag.
+// Other types return ErrNotValidatable.
+// The messages are translated to the first locale unless translation is false.
 func (v *defaultValidator) ValidateStruct(obj interface{}, translation ...bool) error {
 	t := true
 	if len(translation) > 0 {
 		t = translation[0]
 	}
+	return v.validateStruct(context.Background(), obj, t, nil)
+}
+
+// ValidateStructLocale is ValidateStruct translating the messages to the first supported locale.
+func (v *defaultValidator) ValidateStructLocale(obj interface{}, locales ...string) error {
+	return v.validateStruct(context.Background(), obj, true, locales)
+}
+
+// ValidateStructCtx is ValidateStructLocale with a context.
+func (v *defaultValidator) ValidateStructCtx(ctx context.Context, obj interface{}, locales ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := v.validateStruct(ctx, obj, true, locales)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// Sanitizes reports whether Config.Sanitize is set.
+func (v *defaultValidator) Sanitizes() bool {
+	return v.config.Sanitize
+}
+
+func (v *defaultValidator) validateStruct(ctx context.Context, obj interface{}, t bool, locales []string) error {
+	if obj == nil {
+		return fmt.Errorf("%w: nil", ErrNotValidatable)
+	}
+	if v.config.Sanitize {
+		if err := Sanitize(obj); err != nil {
+			return err
+		}
+	}
 	value := reflect.ValueOf(obj)
-	valueType := value.Kind()
-	if valueType == reflect.Ptr {
-		valueType = value.Elem().Kind()
-	}
-	if valueType == reflect.Struct {
-		v.lazyInit(t)
-		if err := v.validate.Struct(obj); err != nil {
-			if tErr, ok := err.(validator.ValidationErrors); ok {
-				var list []string
-				for _, v := range tErr.Translate(v.trans) {
-					//list = append(list, fmt.Sprintf("Key: %s Error: %s", k, v))
-					list = append(list, v)
-				}
-				result := strings.Join(list, ", ")
-				return errors.New(result)
+	switch indirectType(value.Type()).Kind() {
+	case reflect.Struct:
+		if indirect(value).IsValid() {
+			return v.validateValue(ctx, obj, t, locales)
+		}
+	case reflect.Slice, reflect.Array:
+		if indirectType(indirectType(value.Type()).Elem()).Kind() != reflect.Struct {
+			break
+		}
+		value = indirect(value)
+		var es FieldErrors
+		for i := 0; i < value.Len(); i++ {
+			elem := value.Index(i)
+			if !indirect(elem).IsValid() {
+				continue
+			}
+			err := v.validateValue(ctx, elem.Interface(), t, locales)
+			if err == nil {
+				continue
 			}
+			errs, ok := err.(FieldErrors)
+			if !ok {
+				return err
+			}
+			es = append(es, errs.prefix("["+strconv.Itoa(i)+"]")...)
+		}
+		if len(es) > 0 {
+			return es
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %T", ErrNotValidatable, obj)
+}
+
+// validateValue validates a struct or a pointer to a struct. The read lock is
+// held during the validation, the rules must not register validations.
+func (v *defaultValidator) validateValue(ctx context.Context, obj interface{}, t bool, locales []string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	e := v.engine
+	var tr ut.Translator
+	if t {
+		tr = e.translator(locales...)
+	}
+	o := v.state.effective[indirectType(reflect.TypeOf(obj))]
+
+	if o != nil {
+		errs, overridden, err := validateOverridden(ctx, e.validate, obj, o)
+		if err != nil {
 			return err
 		}
+		es := append(newFieldErrors(reflect.TypeOf(obj), errs, tr), newFieldErrors(o.synth, overridden, tr)...)
+		if len(es) > 0 {
+			sortFieldErrors(es, indirectType(reflect.TypeOf(obj)))
+			return es
+		}
+		return nil
+	}
+	if err := e.validate.StructCtx(ctx, obj); err != nil {
+		if tErr, ok := err.(validator.ValidationErrors); ok {
+			return newFieldErrors(reflect.TypeOf(obj), tErr, tr)
+		}
+		return err
 	}
 	return nil
 }
@@ -61,41 +282,52 @@ func (v *defaultValidator) ValidateStruct(obj interface{}, translation ...bool)
 // Validator instance. This is useful if you want to register custom validations
 // or struct level validations. See validator GoDoc for more info -
 // https://godoc.org/gopkg.in/go-playground/validator.v8
+// Registrations on the engine are not synchronized with the validations,
+// they must be done before the Validator is used. translation is ignored,
+// it's kept for compatibility, see Config.DisableTranslation.
 func (v *defaultValidator) Engine(translation ...bool) interface{} {
-	t := true
-	if len(translation) > 0 {
-		t = translation[0]
-	}
-	v.lazyInit(t)
-	return v.validate
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.engine.validate
 }
 
-func (v *defaultValidator) lazyInit(translation bool) {
-	v.once.Do(func() {
-		v.validate = validator.New()
-		v.validate.SetTagName("valid")
-		_ = v.validate.RegisterValidation("ck_np", checkNewPassword)
-		if translation {
-			cn := zh.New()
-			uni := ut.New(cn, cn)
-			v.trans, _ = uni.GetTranslator("zh")
-			_ = zhTranslations.RegisterDefaultTranslations(v.validate, v.trans)
-		}
+func (v *defaultValidator) RegisterValidation(tag string, fn validator.Func, callValidationEvenIfNull ...bool) error {
+	return v.register(func(e *engine) error {
+		return e.validate.RegisterValidation(tag, fn, callValidationEvenIfNull...)
 	})
 }
 
-func (v *defaultValidator) RegisterValidation(tag string, fn validator.Func, callValidationEvenIfNull ...bool) error {
-	return v.validate.RegisterValidation(tag, fn, callValidationEvenIfNull...)
+func (v *defaultValidator) RegisterValidationCtx(tag string, fn validator.FuncCtx, callValidationEvenIfNull ...bool) error {
+	return v.register(func(e *engine) error {
+		return e.validate.RegisterValidationCtx(tag, fn, callValidationEvenIfNull...)
+	})
+}
+
+func (v *defaultValidator) RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	_ = v.register(func(e *engine) error {
+		e.validate.RegisterStructValidation(fn, types...)
+		return nil
+	})
 }
 
-// checkNewPassword：当password不为空时，需检查NewPassword也不能为空
-func checkNewPassword(fl validator.FieldLevel) bool {
-	p := fl.Field()
-	if p.Len() > 0 {
-		np := fl.Parent().Elem().FieldByName("NewPassword")
-		if np.Len() > 0 {
-			return true
+func (v *defaultValidator) RegisterStructValidationCtx(fn validator.StructLevelFuncCtx, types ...interface{}) {
+	_ = v.register(func(e *engine) error {
+		e.validate.RegisterStructValidationCtx(fn, types...)
+		return nil
+	})
+}
+
+func (v *defaultValidator) RegisterValidationWithTranslation(tag string, fn validator.Func, templates map[string]string, callValidationEvenIfNull ...bool) error {
+	return v.register(func(e *engine) error {
+		if err := e.validate.RegisterValidation(tag, fn, callValidationEvenIfNull...); err != nil {
+			return err
 		}
-	}
-	return false
+		return e.registerTranslation(tag, templates, nil)
+	})
+}
+
+func (v *defaultValidator) RegisterTranslation(tag string, templates map[string]string) error {
+	return v.register(func(e *engine) error {
+		return e.registerTranslation(tag, templates, nil)
+	})
 }
@@ -0,0 +1,129 @@
+package validator
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type signup struct {
+	Name string `json:"name" valid:"required"`
+}
+
+type coupon struct {
+	Name string `json:"name" valid:"required"`
+	Code string `json:"code" valid:"omitempty,even"`
+}
+
+func even(fl validator.FieldLevel) bool {
+	n, err := strconv.Atoi(fl.Field().String())
+	return err == nil && n%2 == 0
+}
+
+func message(t *testing.T, err error, field string) string {
+	t.Helper()
+	es, ok := AsFieldErrors(err)
+	if !ok {
+		t.Fatalf("expected FieldErrors but got %v", err)
+	}
+	return es.Message(field)
+}
+
+func TestTranslationToggle(t *testing.T) {
+	// The first call used to fix the translation for good, whatever the later calls asked.
+	v := NewValidator()
+	untranslated := message(t, v.ValidateStruct(&signup{}, false), "name")
+	if !strings.Contains(untranslated, "'required' tag") {
+		t.Fatalf("expected the untranslated message but got %q", untranslated)
+	}
+	if got := message(t, v.ValidateStruct(&signup{}), "name"); got != "Name为必填字段" {
+		t.Fatalf("expected the translated message but got %q", got)
+	}
+	if got := message(t, v.ValidateStruct(&signup{}, false), "name"); got != untranslated {
+		t.Fatalf("expected %q but got %q", untranslated, got)
+	}
+	if got := message(t, v.ValidateStructLocale(&signup{}, "en-US"), "name"); got != "Name is a required field" {
+		t.Fatalf("expected the English message but got %q", got)
+	}
+}
+
+func TestDisableTranslation(t *testing.T) {
+	v := NewValidator(Config{DisableTranslation: true})
+	for _, err := range []error{v.ValidateStruct(&signup{}), v.ValidateStructLocale(&signup{}, "en")} {
+		if got := message(t, err, "name"); !strings.Contains(got, "'required' tag") {
+			t.Fatalf("expected the untranslated message but got %q", got)
+		}
+	}
+}
+
+func TestRegisterValidationBeforeUse(t *testing.T) {
+	v := NewValidator()
+	if err := v.RegisterValidation("even", even); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.ValidateStruct(&coupon{Name: "a", Code: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	es, _ := AsFieldErrors(v.ValidateStruct(&coupon{Name: "a", Code: "3"}))
+	if e, ok := es.Get("code"); !ok || e.Tag != "even" {
+		t.Fatalf("expected the even rule to fail but got %v", es)
+	}
+}
+
+func TestEngineRegistrationSurvivesRegisterValidation(t *testing.T) {
+	v := NewValidator()
+	if err := v.Engine().(*validator.Validate).RegisterValidation("even", even); err != nil {
+		t.Fatal(err)
+	}
+	// Used to replace the engine, dropping "even" and panicking on the undefined tag below.
+	if err := v.RegisterValidation("odd", func(fl validator.FieldLevel) bool { return !even(fl) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.RegisterTranslation("even", map[string]string{"en": "{0} must be even"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.ValidateStruct(&coupon{Name: "a", Code: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := message(t, v.ValidateStructLocale(&coupon{Name: "a", Code: "3"}, "en"), "code"); msg != "Code must be even" {
+		t.Fatalf("expected the even message but got %q", msg)
+	}
+}
+
+func TestConcurrentRegisterAndValidate(t *testing.T) {
+	v := NewValidator()
+	if err := v.RegisterValidation("even", even); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			tag := "rule" + strconv.Itoa(i)
+			if err := v.RegisterValidation(tag, even); err != nil {
+				t.Error(err)
+			}
+			if err := v.RegisterTranslation(tag, map[string]string{"en": "{0} must be even"}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				translation := j%2 == 0
+				err := v.ValidateStruct(&coupon{Name: "a", Code: strconv.Itoa(i + j)}, translation)
+				if (i+j)%2 == 0 && err != nil {
+					t.Error(err)
+				}
+				if (i+j)%2 != 0 && err == nil {
+					t.Errorf("expected %d to fail", i+j)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}